@@ -2,7 +2,8 @@ package annotations
 
 const (
 	// AnnLinodeDefaultProtocol is the annotation used to specify the default protocol
-	// for Linode load balancers. Options are tcp, http and https. Defaults to tcp.
+	// for Linode load balancers. Options are tcp, http and https. Defaults to the
+	// --default-protocol.
 	AnnLinodeDefaultProtocol      = "service.beta.kubernetes.io/linode-loadbalancer-default-protocol"
 	AnnLinodePortConfigPrefix     = "service.beta.kubernetes.io/linode-loadbalancer-port-"
 	AnnLinodeDefaultProxyProtocol = "service.beta.kubernetes.io/linode-loadbalancer-default-proxy-protocol"
@@ -16,11 +17,43 @@ const (
 	AnnLinodeHealthCheckAttempts = "service.beta.kubernetes.io/linode-loadbalancer-check-attempts"
 	AnnLinodeHealthCheckPassive  = "service.beta.kubernetes.io/linode-loadbalancer-check-passive"
 
+	// AnnLinodeLoadBalancerAlgorithm is the balancing algorithm of every port
+	// of the NodeBalancer. Options are roundrobin, leastconn and source for
+	// TCP-based ports, and roundrobin, leastconn and ring_hash for UDP ports.
+	// Defaults to roundrobin.
+	AnnLinodeLoadBalancerAlgorithm = "service.beta.kubernetes.io/linode-loadbalancer-algorithm"
+	// AnnLinodeLoadBalancerStickiness is the session stickiness of every port
+	// of the NodeBalancer. Options are none, table and http_cookie (http and
+	// https ports only) for TCP-based ports, and none, session and source_ip
+	// for UDP ports. Defaults to none, or session for UDP ports.
+	AnnLinodeLoadBalancerStickiness = "service.beta.kubernetes.io/linode-loadbalancer-stickiness"
+
 	// AnnLinodeThrottle is the annotation specifying the value of the Client Connection
 	// Throttle, which limits the number of subsequent new connections per second from the
-	// same client IP. Options are a number between 1-20, or 0 to disable. Defaults to 20.
+	// same client IP. Options are a number between 1-20, or 0 to disable. Defaults to the
+	// --default-throttle.
 	AnnLinodeThrottle = "service.beta.kubernetes.io/linode-loadbalancer-throttle"
 
+	// AnnLinodeLoadBalancerIP is the IP the Service must be reachable on. It
+	// takes precedence over the deprecated spec.loadBalancerIP. NodeBalancers
+	// cannot be created with a given IP, so an existing NodeBalancer with
+	// that IP is adopted. With cilium-bgp, the IP must be held by the
+	// ip-holder.
+	AnnLinodeLoadBalancerIP = "service.beta.kubernetes.io/linode-loadbalancer-ip"
+
+	// AnnLinodeLoadBalancerType is the type of load balancer of the Service.
+	// Options are nodebalancer and cilium-bgp. Defaults to the
+	// --load-balancer-type. Changing it migrates the Service to the new type,
+	// serving it from both load balancers until the cutover.
+	AnnLinodeLoadBalancerType = "service.beta.kubernetes.io/linode-loadbalancer-type"
+	// AnnLinodeLoadBalancerCutover confirms the cutover of a migrating Service
+	// when set to its new type, after which the old load balancer is deleted.
+	AnnLinodeLoadBalancerCutover = "service.beta.kubernetes.io/linode-loadbalancer-cutover"
+	// AnnLinodeLoadBalancerProvisionedType is set by the CCM to the type of
+	// load balancer serving the Service, unless it is the default type. It is
+	// used to detect migrations.
+	AnnLinodeLoadBalancerProvisionedType = "service.beta.kubernetes.io/linode-loadbalancer-provisioned-type"
+
 	AnnLinodeLoadBalancerPreserve = "service.beta.kubernetes.io/linode-loadbalancer-preserve"
 	AnnLinodeNodeBalancerID       = "service.beta.kubernetes.io/linode-loadbalancer-nodebalancer-id"
 
@@ -28,9 +61,37 @@ const (
 	AnnLinodeLoadBalancerTags    = "service.beta.kubernetes.io/linode-loadbalancer-tags"
 	AnnLinodeCloudFirewallID     = "service.beta.kubernetes.io/linode-loadbalancer-firewall-id"
 	AnnLinodeCloudFirewallACL    = "service.beta.kubernetes.io/linode-loadbalancer-firewall-acl"
+	// AnnLinodeCloudFirewallACLConfigMap is the name of a ConfigMap, in the
+	// namespace of the Service, whose acl key holds the firewall ACL. It is
+	// used instead of AnnLinodeCloudFirewallACL to share an ACL between
+	// Services, whose firewalls are updated when the ConfigMap changes.
+	AnnLinodeCloudFirewallACLConfigMap = "service.beta.kubernetes.io/linode-loadbalancer-firewall-acl-configmap"
+
+	// AnnLinodeNodeBalancerBackendVPCName is the VPC the NodeBalancer is
+	// attached to, so that it reaches its backends through their VPC IPs.
+	// Must be one of the --vpc-names, and defaults to the first of them when
+	// VPC backends are enabled.
+	AnnLinodeNodeBalancerBackendVPCName = "service.beta.kubernetes.io/linode-loadbalancer-backend-vpc-name"
+	// AnnLinodeNodeBalancerBackendSubnetName is the subnet of the VPC the
+	// NodeBalancer is attached to. Must be one of the --subnet-names if set,
+	// and defaults to the first of them, or to the subnet of the backends.
+	AnnLinodeNodeBalancerBackendSubnetName = "service.beta.kubernetes.io/linode-loadbalancer-backend-subnet-name"
+
+	// AnnLinodeTLSFingerprints is set by the CCM to the SHA-256 fingerprints of
+	// the certificates served on the HTTPS ports of the NodeBalancer, as a JSON
+	// object keyed by port. It is used to detect renewed certificates.
+	AnnLinodeTLSFingerprints = "service.beta.kubernetes.io/linode-loadbalancer-tls-fingerprints"
 
 	AnnLinodeNodePrivateIP = "node.k8s.linode.com/private-ip"
 	AnnLinodeHostUUID      = "node.k8s.linode.com/host-uuid"
 
+	// AnnLinodeNodeBalancerBackendWeight is the weight, between 1 and 255, of
+	// the node in every NodeBalancer it backs. Defaults to 100.
+	AnnLinodeNodeBalancerBackendWeight = "node.k8s.linode.com/nodebalancer-backend-weight"
+	// AnnLinodeNodeBalancerBackendMode is the mode of the node in every
+	// NodeBalancer it backs. Options are accept, reject, drain and backup.
+	// Defaults to accept, or drain for cordoned nodes.
+	AnnLinodeNodeBalancerBackendMode = "node.k8s.linode.com/nodebalancer-backend-mode"
+
 	AnnLinodeNodeIPSharingUpdated = "node.k8s.linode.com/ip-sharing-updated"
 )
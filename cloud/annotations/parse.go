@@ -0,0 +1,123 @@
+package annotations
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ParseError is returned by the getters when the value of an annotation
+// cannot be parsed. The value is never replaced by a default or clamped into
+// range, so that a typo is reported instead of silently changing behaviour.
+type ParseError struct {
+	Annotation string
+	Value      string
+	// Reason describes the expected value, e.g. "is not a boolean"
+	Reason string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("annotation %s: %q %s", e.Annotation, e.Value, e.Reason)
+}
+
+// GetString returns the value of annotation ann of obj, or def if it is not
+// set.
+func GetString(obj metav1.Object, ann, def string) string {
+	if value, ok := obj.GetAnnotations()[ann]; ok {
+		return value
+	}
+	return def
+}
+
+// GetBool returns the boolean value of annotation ann of obj, or def if it is
+// not set.
+func GetBool(obj metav1.Object, ann string, def bool) (bool, error) {
+	value, ok := obj.GetAnnotations()[ann]
+	if !ok {
+		return def, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return def, &ParseError{Annotation: ann, Value: value, Reason: "is not a boolean"}
+	}
+	return parsed, nil
+}
+
+// GetInt returns the integer value of annotation ann of obj, or def if it is
+// not set.
+func GetInt(obj metav1.Object, ann string, def int) (int, error) {
+	value, ok := obj.GetAnnotations()[ann]
+	if !ok {
+		return def, nil
+	}
+	parsed, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return def, &ParseError{Annotation: ann, Value: value, Reason: "is not an integer"}
+	}
+	return parsed, nil
+}
+
+// GetIntInRange returns the integer value, between minValue and maxValue, of
+// annotation ann of obj, or def if it is not set.
+func GetIntInRange(obj metav1.Object, ann string, def, minValue, maxValue int) (int, error) {
+	value, ok := obj.GetAnnotations()[ann]
+	if !ok {
+		return def, nil
+	}
+	parsed, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || parsed < minValue || parsed > maxValue {
+		return def, &ParseError{Annotation: ann, Value: value, Reason: fmt.Sprintf("must be an integer between %d and %d", minValue, maxValue)}
+	}
+	return parsed, nil
+}
+
+// GetEnum returns the value of annotation ann of obj, which must be one of
+// options, or def if it is not set.
+func GetEnum[T ~string](obj metav1.Object, ann string, def T, options ...T) (T, error) {
+	value, ok := obj.GetAnnotations()[ann]
+	if !ok {
+		return def, nil
+	}
+	for _, option := range options {
+		if T(value) == option {
+			return option, nil
+		}
+	}
+	names := make([]string, 0, len(options))
+	for _, option := range options {
+		names = append(names, string(option))
+	}
+	return def, &ParseError{Annotation: ann, Value: value, Reason: "must be one of " + strings.Join(names, ", ")}
+}
+
+// GetStringSlice returns the comma separated values of annotation ann of obj,
+// without surrounding spaces and empty values, or nil if it is not set.
+func GetStringSlice(obj metav1.Object, ann string) []string {
+	value, ok := obj.GetAnnotations()[ann]
+	if !ok {
+		return nil
+	}
+	values := []string{}
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// GetJSON unmarshals the value of annotation ann of obj into v, and reports
+// whether the annotation is set.
+func GetJSON(obj metav1.Object, ann string, v any) (bool, error) {
+	value, ok := obj.GetAnnotations()[ann]
+	if !ok {
+		return false, nil
+	}
+	if err := json.Unmarshal([]byte(value), v); err != nil {
+		return true, &ParseError{Annotation: ann, Value: value, Reason: fmt.Sprintf("is not valid JSON: %s", err)}
+	}
+	return true, nil
+}
@@ -0,0 +1,100 @@
+package annotations
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const testAnnotation = "service.beta.kubernetes.io/linode-loadbalancer-test"
+
+func newTestObject(value *string) metav1.Object {
+	obj := &metav1.ObjectMeta{}
+	if value != nil {
+		obj.Annotations = map[string]string{testAnnotation: *value}
+	}
+	return obj
+}
+
+func ptr(s string) *string { return &s }
+
+func TestGetIntInRange(t *testing.T) {
+	testcases := []struct {
+		name     string
+		value    *string
+		expected int
+		err      string
+	}{
+		{name: "not set", expected: 3},
+		{name: "valid", value: ptr("20"), expected: 20},
+		{name: "surrounding spaces", value: ptr(" 7 "), expected: 7},
+		{name: "not an integer", value: ptr("foo"), expected: 3, err: `annotation service.beta.kubernetes.io/linode-loadbalancer-test: "foo" must be an integer between 0 and 20`},
+		{name: "below the range", value: ptr("-1"), expected: 3, err: `annotation service.beta.kubernetes.io/linode-loadbalancer-test: "-1" must be an integer between 0 and 20`},
+		{name: "above the range", value: ptr("21"), expected: 3, err: `annotation service.beta.kubernetes.io/linode-loadbalancer-test: "21" must be an integer between 0 and 20`},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.name, func(t *testing.T) {
+			value, err := GetIntInRange(newTestObject(test.value), testAnnotation, 3, 0, 20)
+			if value != test.expected {
+				t.Errorf("expected %d, got %d", test.expected, value)
+			}
+			if (err == nil && test.err != "") || (err != nil && err.Error() != test.err) {
+				t.Errorf("expected error %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestGetBool(t *testing.T) {
+	if value, err := GetBool(newTestObject(nil), testAnnotation, true); err != nil || !value {
+		t.Errorf("expected the default, got %t, %v", value, err)
+	}
+	if value, err := GetBool(newTestObject(ptr("false")), testAnnotation, true); err != nil || value {
+		t.Errorf("expected false, got %t, %v", value, err)
+	}
+
+	_, err := GetBool(newTestObject(ptr("yes")), testAnnotation, false)
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Annotation != testAnnotation || parseErr.Value != "yes" {
+		t.Errorf("expected a ParseError, got %v", err)
+	}
+}
+
+func TestGetEnum(t *testing.T) {
+	type mode string
+	if value, err := GetEnum(newTestObject(nil), testAnnotation, mode("a"), "a", "b"); err != nil || value != "a" {
+		t.Errorf("expected the default, got %s, %v", value, err)
+	}
+	if value, err := GetEnum(newTestObject(ptr("b")), testAnnotation, mode("a"), "a", "b"); err != nil || value != "b" {
+		t.Errorf("expected b, got %s, %v", value, err)
+	}
+	expected := `annotation service.beta.kubernetes.io/linode-loadbalancer-test: "c" must be one of a, b`
+	if _, err := GetEnum(newTestObject(ptr("c")), testAnnotation, mode("a"), "a", "b"); err == nil || err.Error() != expected {
+		t.Errorf("expected error %q, got %v", expected, err)
+	}
+}
+
+func TestGetStringSlice(t *testing.T) {
+	if values := GetStringSlice(newTestObject(nil), testAnnotation); values != nil {
+		t.Errorf("expected nil, got %v", values)
+	}
+	if values := GetStringSlice(newTestObject(ptr("a, b,,c ")), testAnnotation); !reflect.DeepEqual(values, []string{"a", "b", "c"}) {
+		t.Errorf("expected [a b c], got %v", values)
+	}
+}
+
+func TestGetJSON(t *testing.T) {
+	var v struct{ Port int }
+	if ok, err := GetJSON(newTestObject(nil), testAnnotation, &v); ok || err != nil {
+		t.Errorf("expected the annotation to be unset, got %t, %v", ok, err)
+	}
+	if ok, err := GetJSON(newTestObject(ptr(`{"port": 80}`)), testAnnotation, &v); !ok || err != nil || v.Port != 80 {
+		t.Errorf("expected port 80, got %d, %v", v.Port, err)
+	}
+	if _, err := GetJSON(newTestObject(ptr(`{"port":`)), testAnnotation, &v); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+}
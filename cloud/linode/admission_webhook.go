@@ -0,0 +1,269 @@
+package linode
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/linode/linodego"
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/linode/linode-cloud-controller-manager/cloud/annotations"
+	"github.com/linode/linode-cloud-controller-manager/cloud/linode/firewall"
+)
+
+const (
+	// admissionWebhookPath is the path of the Service validation endpoint.
+	admissionWebhookPath = "/validate-service"
+	// the API server gives up on webhooks after at most 30 seconds
+	admissionWebhookTimeout = 30 * time.Second
+	// ipFeedValidationTimeout bounds the fetch of the IP feeds an ACL
+	// references, within the admissionWebhookTimeout
+	ipFeedValidationTimeout = 10 * time.Second
+	// maxConnThrottle is the highest client connection throttle a
+	// NodeBalancer accepts
+	maxConnThrottle = 20
+	// linodeAnnotationPrefix prefixes the Service annotations read by the CCM
+	linodeAnnotationPrefix = "service.beta.kubernetes.io/linode-"
+)
+
+// admissionWebhook rejects LoadBalancer Services whose Linode annotations
+// would fail to reconcile, so that mistakes are reported by kubectl apply
+// instead of leaving the Service pending. It runs on every CCM replica, as
+// opposed to the controllers which only run on the leader.
+type admissionWebhook struct {
+	address string
+	certDir string
+	ipFeeds *firewall.IPFeeds
+}
+
+func newAdmissionWebhook(address, certDir string, ipFeeds *firewall.IPFeeds) *admissionWebhook {
+	return &admissionWebhook{
+		address: address,
+		certDir: certDir,
+		ipFeeds: ipFeeds,
+	}
+}
+
+func (a *admissionWebhook) Run() {
+	certFile := filepath.Join(a.certDir, v1.TLSCertKey)
+	keyFile := filepath.Join(a.certDir, v1.TLSPrivateKeyKey)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(admissionWebhookPath, a.serveValidate)
+	server := &http.Server{
+		Addr:              a.address,
+		Handler:           mux,
+		ReadHeaderTimeout: admissionWebhookTimeout,
+		TLSConfig: &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			// load the certificate on each handshake, so renewed certificates
+			// are served without restarting the CCM
+			GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+				cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+				if err != nil {
+					return nil, err
+				}
+				return &cert, nil
+			},
+		},
+	}
+
+	klog.Infof("AdmissionWebhook serving %s on %s", admissionWebhookPath, a.address)
+	if err := server.ListenAndServeTLS("", ""); err != nil {
+		klog.Errorf("AdmissionWebhook stopped serving: %s", err)
+	}
+}
+
+func (a *admissionWebhook) serveValidate(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var review admissionv1.AdmissionReview
+	if err = json.Unmarshal(body, &review); err != nil || review.Request == nil {
+		http.Error(w, fmt.Sprintf("invalid AdmissionReview: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	review.Response = a.validate(review.Request)
+	review.Request = nil
+	resp, err := json.Marshal(review)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(resp)
+}
+
+// validate returns the admission response for a Service create or update.
+func (a *admissionWebhook) validate(request *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	response := &admissionv1.AdmissionResponse{UID: request.UID, Allowed: true}
+
+	var service v1.Service
+	if err := json.Unmarshal(request.Object.Raw, &service); err != nil {
+		response.Allowed = false
+		response.Result = &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Reason:  metav1.StatusReasonBadRequest,
+			Message: fmt.Sprintf("failed to decode service: %s", err),
+			Code:    http.StatusBadRequest,
+		}
+		return response
+	}
+
+	// Services being deleted must be able to drop their finalizers, and
+	// updates that leave the Linode settings alone, e.g. of labels, must not
+	// be blocked by settings that were accepted before or an IP feed that
+	// changed since.
+	if service.DeletionTimestamp != nil {
+		return response
+	}
+	if request.Operation == admissionv1.Update && len(request.OldObject.Raw) > 0 {
+		var oldService v1.Service
+		if err := json.Unmarshal(request.OldObject.Raw, &oldService); err == nil && !linodeSettingsChanged(&oldService, &service) {
+			return response
+		}
+	}
+
+	if errs := validateServiceAnnotations(&service, a.ipFeeds); len(errs) > 0 {
+		klog.V(3).Infof("AdmissionWebhook rejected service (%s/%s): %v", request.Namespace, request.Name, errs)
+		response.Allowed = false
+		response.Result = &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Reason:  metav1.StatusReasonInvalid,
+			Message: fmt.Sprintf("invalid Linode annotations: %s", strings.Join(errs, "; ")),
+			Code:    http.StatusUnprocessableEntity,
+		}
+	}
+	return response
+}
+
+// linodeSettingsChanged reports whether an update of a Service changes what
+// validateServiceAnnotations checks: its type, ports or Linode annotations.
+func linodeSettingsChanged(oldService, service *v1.Service) bool {
+	if oldService.Spec.Type != service.Spec.Type || !reflect.DeepEqual(oldService.Spec.Ports, service.Spec.Ports) {
+		return true
+	}
+	linodeAnnotations := func(service *v1.Service) map[string]string {
+		filtered := make(map[string]string)
+		for key, value := range service.GetAnnotations() {
+			if strings.HasPrefix(key, linodeAnnotationPrefix) {
+				filtered[key] = value
+			}
+		}
+		return filtered
+	}
+	return !reflect.DeepEqual(linodeAnnotations(oldService), linodeAnnotations(service))
+}
+
+// validateServiceAnnotations returns a message for each Linode annotation of
+// a LoadBalancer Service that the CCM would fail to reconcile. It relies on
+// the same parsing as the reconcile, so the messages match the errors the
+// Service would otherwise only report through events.
+func validateServiceAnnotations(service *v1.Service, ipFeeds *firewall.IPFeeds) []string {
+	if service.Spec.Type != v1.ServiceTypeLoadBalancer || !claimsLoadBalancerClass(Options.LoadBalancerClasses, service) {
+		return nil
+	}
+	var errs []string
+	serviceAnnotations := service.GetAnnotations()
+
+	if err := validateServicePorts(service); err != nil {
+		errs = append(errs, err.Error())
+	}
+
+	health, err := getHealthCheckType(service)
+	if err != nil {
+		errs = append(errs, err.Error())
+	}
+	for _, check := range []struct {
+		ann                string
+		minValue, maxValue int
+	}{
+		{annotations.AnnLinodeHealthCheckInterval, 2, 3600},
+		{annotations.AnnLinodeHealthCheckTimeout, 1, 30},
+		{annotations.AnnLinodeHealthCheckAttempts, 1, 30},
+		{annotations.AnnLinodeThrottle, 0, maxConnThrottle},
+	} {
+		if _, err = annotations.GetIntInRange(service, check.ann, 0, check.minValue, check.maxValue); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	for _, ann := range []string{annotations.AnnLinodeHealthCheckPassive, annotations.AnnLinodeLoadBalancerPreserve, annotations.AnnLinodeHostnameOnlyIngress} {
+		if _, err = annotations.GetBool(service, ann, false); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	for _, ann := range []string{annotations.AnnLinodeLoadBalancerType, annotations.AnnLinodeLoadBalancerCutover} {
+		if value, ok := serviceAnnotations[ann]; ok {
+			if err = validateLoadBalancerType(value); err != nil {
+				errs = append(errs, fmt.Sprintf("annotation %s: %s", ann, err))
+			}
+		}
+	}
+
+	if value, ok := serviceAnnotations[annotations.AnnLinodeLoadBalancerIP]; ok && net.ParseIP(strings.TrimSpace(value)) == nil {
+		errs = append(errs, fmt.Sprintf("annotation %s: %q is not an IP address", annotations.AnnLinodeLoadBalancerIP, value))
+	}
+
+	for _, ann := range []string{annotations.AnnLinodeNodeBalancerID, annotations.AnnLinodeCloudFirewallID} {
+		if _, err = annotations.GetInt(service, ann, 0); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if _, ok := serviceAnnotations[annotations.AnnLinodeCloudFirewallACL]; ok {
+		fwClient := firewall.LinodeClient{Feeds: ipFeeds}
+		// an IP feed that cannot be fetched is not a mistake of the Service
+		ctx, cancel := context.WithTimeout(context.Background(), ipFeedValidationTimeout)
+		_, err = fwClient.CreateFirewallOpts(ctx, service.Name, nil, service)
+		cancel()
+		if err != nil && !errors.Is(err, firewall.ErrIPFeedUnavailable) {
+			errs = append(errs, annotationError(annotations.AnnLinodeCloudFirewallACL, err))
+		}
+		if value, ok := serviceAnnotations[annotations.AnnLinodeCloudFirewallACLConfigMap]; ok {
+			errs = append(errs, fmt.Sprintf("annotation %s: %q cannot be set with %s", annotations.AnnLinodeCloudFirewallACLConfigMap, value, annotations.AnnLinodeCloudFirewallACL))
+		}
+	}
+
+	for _, port := range service.Spec.Ports {
+		config, err := getPortConfig(service, int(port.Port))
+		if err != nil {
+			errs = append(errs, annotationError(fmt.Sprintf("%s%d", annotations.AnnLinodePortConfigPrefix, port.Port), err))
+			continue
+		}
+		check := config.Check
+		if check == "" {
+			check = health
+		}
+		if check == linodego.CheckHTTPBody && config.CheckBody == "" && serviceAnnotations[annotations.AnnLinodeCheckBody] == "" {
+			errs = append(errs, fmt.Sprintf("port %d: health check type http_body requires the %s annotation", port.Port, annotations.AnnLinodeCheckBody))
+		}
+	}
+	return errs
+}
+
+// annotationError returns the message of err, caused by annotation ann, which
+// annotations.ParseError messages already name.
+func annotationError(ann string, err error) string {
+	var parseErr *annotations.ParseError
+	if errors.As(err, &parseErr) {
+		return err.Error()
+	}
+	return fmt.Sprintf("annotation %s: %s", ann, err)
+}
@@ -0,0 +1,197 @@
+package linode
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/linode/linode-cloud-controller-manager/cloud/annotations"
+)
+
+func Test_validateServiceAnnotations(t *testing.T) {
+	testcases := []struct {
+		name        string
+		serviceType v1.ServiceType
+		annotations map[string]string
+		errs        []string
+	}{
+		{
+			name: "valid",
+			annotations: map[string]string{
+				annotations.AnnLinodeThrottle:                "20",
+				annotations.AnnLinodeHealthCheckType:         "http",
+				annotations.AnnLinodeCloudFirewallACL:        `{"allowList": {"ipv4": ["1.2.3.4/32"]}}`,
+				annotations.AnnLinodePortConfigPrefix + "80": `{"protocol": "http"}`,
+			},
+		},
+		{
+			name:        "not a LoadBalancer",
+			serviceType: v1.ServiceTypeClusterIP,
+			annotations: map[string]string{annotations.AnnLinodeThrottle: "-1"},
+		},
+		{
+			name:        "throttle out of range",
+			annotations: map[string]string{annotations.AnnLinodeThrottle: "25"},
+			errs:        []string{`annotation service.beta.kubernetes.io/linode-loadbalancer-throttle: "25" must be an integer between 0 and 20`},
+		},
+		{
+			name:        "invalid preserve",
+			annotations: map[string]string{annotations.AnnLinodeLoadBalancerPreserve: "yes"},
+			errs:        []string{`annotation service.beta.kubernetes.io/linode-loadbalancer-preserve: "yes" is not a boolean`},
+		},
+		{
+			name:        "invalid health check type",
+			annotations: map[string]string{annotations.AnnLinodeHealthCheckType: "tcp"},
+			errs:        []string{`annotation service.beta.kubernetes.io/linode-loadbalancer-check-type: "tcp" must be one of none, connection, http, http_body`},
+		},
+		{
+			name:        "http_body without body",
+			annotations: map[string]string{annotations.AnnLinodeHealthCheckType: "http_body"},
+			errs:        []string{"port 80: health check type http_body requires the service.beta.kubernetes.io/linode-loadbalancer-check-body annotation"},
+		},
+		{
+			name:        "invalid port config JSON",
+			annotations: map[string]string{annotations.AnnLinodePortConfigPrefix + "80": `{"protocol": "http"`},
+			errs:        []string{`annotation service.beta.kubernetes.io/linode-loadbalancer-port-80: "{\"protocol\": \"http\"" is not valid JSON: unexpected end of JSON input`},
+		},
+		{
+			name:        "invalid port protocol",
+			annotations: map[string]string{annotations.AnnLinodePortConfigPrefix + "80": `{"protocol": "ftp"}`},
+			errs:        []string{`annotation service.beta.kubernetes.io/linode-loadbalancer-port-80: invalid protocol: "ftp" specified`},
+		},
+		{
+			name:        "invalid firewall ACL",
+			annotations: map[string]string{annotations.AnnLinodeCloudFirewallACL: `{}`},
+			errs:        []string{"annotation service.beta.kubernetes.io/linode-loadbalancer-firewall-acl: specify an allowList, a denyList or both, or rules for a firewall"},
+		},
+		{
+			name: "firewall ACL and ACL configmap",
+			annotations: map[string]string{
+				annotations.AnnLinodeCloudFirewallACL:          `{"allowList": {"ipv4": ["1.2.3.4/32"]}}`,
+				annotations.AnnLinodeCloudFirewallACLConfigMap: "office",
+			},
+			errs: []string{`annotation service.beta.kubernetes.io/linode-loadbalancer-firewall-acl-configmap: "office" cannot be set with service.beta.kubernetes.io/linode-loadbalancer-firewall-acl`},
+		},
+		{
+			name:        "invalid load balancer IP",
+			annotations: map[string]string{annotations.AnnLinodeLoadBalancerIP: "1.2.3"},
+			errs:        []string{`annotation service.beta.kubernetes.io/linode-loadbalancer-ip: "1.2.3" is not an IP address`},
+		},
+		{
+			name:        "unsupported load balancer type",
+			annotations: map[string]string{annotations.AnnLinodeLoadBalancerType: "metallb"},
+			errs:        []string{`annotation service.beta.kubernetes.io/linode-loadbalancer-type: unsupported load balancer type "metallb", options are [cilium-bgp nodebalancer]`},
+		},
+		{
+			name: "multiple errors",
+			annotations: map[string]string{
+				annotations.AnnLinodeCloudFirewallID:     "abc",
+				annotations.AnnLinodeHealthCheckInterval: "5s",
+			},
+			errs: []string{
+				`annotation service.beta.kubernetes.io/linode-loadbalancer-check-interval: "5s" must be an integer between 2 and 3600`,
+				`annotation service.beta.kubernetes.io/linode-loadbalancer-firewall-id: "abc" is not an integer`,
+			},
+		},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.name, func(t *testing.T) {
+			serviceType := test.serviceType
+			if serviceType == "" {
+				serviceType = v1.ServiceTypeLoadBalancer
+			}
+			service := &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Annotations: test.annotations},
+				Spec: v1.ServiceSpec{
+					Type:  serviceType,
+					Ports: []v1.ServicePort{{Protocol: v1.ProtocolTCP, Port: 80}},
+				},
+			}
+			assert.Equal(t, test.errs, validateServiceAnnotations(service, nil))
+		})
+	}
+}
+
+func Test_admissionWebhook_serveValidate(t *testing.T) {
+	webhook := newAdmissionWebhook(":0", "", nil)
+
+	// review returns the response to the creation of service, or to its
+	// update from oldService if set
+	review := func(service, oldService *v1.Service) *admissionv1.AdmissionResponse {
+		t.Helper()
+		raw, err := json.Marshal(service)
+		assert.NoError(t, err)
+		request := &admissionv1.AdmissionRequest{
+			UID:       "uid",
+			Operation: admissionv1.Create,
+			Object:    runtime.RawExtension{Raw: raw},
+		}
+		if oldService != nil {
+			request.Operation = admissionv1.Update
+			request.OldObject.Raw, err = json.Marshal(oldService)
+			assert.NoError(t, err)
+		}
+		body, err := json.Marshal(admissionv1.AdmissionReview{
+			TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+			Request:  request,
+		})
+		assert.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+		webhook.serveValidate(rec, httptest.NewRequest(http.MethodPost, admissionWebhookPath, bytes.NewReader(body)))
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var resp admissionv1.AdmissionReview
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, "AdmissionReview", resp.Kind)
+		assert.Equal(t, "uid", string(resp.Response.UID))
+		return resp.Response
+	}
+
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "test"},
+		Spec: v1.ServiceSpec{
+			Type:  v1.ServiceTypeLoadBalancer,
+			Ports: []v1.ServicePort{{Protocol: v1.ProtocolTCP, Port: 80}},
+		},
+	}
+	assert.True(t, review(service, nil).Allowed)
+
+	service.Annotations = map[string]string{annotations.AnnLinodeThrottle: "abc"}
+	resp := review(service, nil)
+	assert.False(t, resp.Allowed)
+	assert.True(t, strings.Contains(resp.Result.Message, annotations.AnnLinodeThrottle), resp.Result.Message)
+
+	// Services being deleted are let through
+	service.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	assert.True(t, review(service, nil).Allowed)
+	service.DeletionTimestamp = nil
+
+	// updates leaving the ports and Linode annotations alone are let through
+	updated := service.DeepCopy()
+	updated.Labels = map[string]string{"team": "a"}
+	updated.Annotations["example.com/owner"] = "team-a"
+	assert.True(t, review(updated, service).Allowed)
+
+	updated.Annotations[annotations.AnnLinodeLoadBalancerTags] = "team-a"
+	assert.False(t, review(updated, service).Allowed)
+
+	updated = service.DeepCopy()
+	updated.Spec.Ports[0].Port = 8080
+	assert.False(t, review(updated, service).Allowed)
+
+	rec := httptest.NewRecorder()
+	webhook.serveValidate(rec, httptest.NewRequest(http.MethodPost, admissionWebhookPath, strings.NewReader("{}")))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
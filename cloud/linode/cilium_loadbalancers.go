@@ -207,18 +207,15 @@ func (l *loadbalancers) handleIPSharing(ctx context.Context, node *v1.Node, ipHo
 }
 
 // createSharedIP requests an additional IP that can be shared on Nodes to support
-// loadbalancing via Cilium LB IPAM + BGP Control Plane.
-func (l *loadbalancers) createSharedIP(ctx context.Context, nodes []*v1.Node, ipHolderSuffix string) (string, error) {
+// loadbalancing via Cilium LB IPAM + BGP Control Plane. When requestedIP is set,
+// that IP, which must be held by the ip-holder without being assigned to another
+// Service, is shared instead.
+func (l *loadbalancers) createSharedIP(ctx context.Context, nodes []*v1.Node, ipHolderSuffix, requestedIP string) (string, error) {
 	ipHolder, err := l.ensureIPHolder(ctx, ipHolderSuffix)
 	if err != nil {
 		return "", err
 	}
 
-	newSharedIP, err := l.client.AddInstanceIPAddress(ctx, ipHolder.ID, true)
-	if err != nil {
-		return "", err
-	}
-
 	// need to retrieve existing public IPs on the IP holder since ShareIPAddresses
 	// expects the full list of IPs to be shared
 	inClusterAddrs, err := l.getExistingSharedIPsInCluster(ctx)
@@ -233,7 +230,24 @@ func (l *loadbalancers) createSharedIP(ctx context.Context, nodes []*v1.Node, ip
 		klog.Infof("error getting shared IPs in cluster: %s", err.Error())
 		return "", err
 	}
-	addrs := []string{newSharedIP.Address}
+
+	sharedIP := requestedIP
+	if sharedIP != "" {
+		if !slices.Contains(ipHolderAddrs, sharedIP) {
+			return "", fmt.Errorf("%w: IP %s is not held by the ip-holder %s", errLoadBalancerIPUnavailable, sharedIP, ipHolder.Label)
+		}
+		if slices.Contains(inClusterAddrs, sharedIP) {
+			return "", fmt.Errorf("%w: IP %s is already assigned to another Service", errLoadBalancerIPUnavailable, sharedIP)
+		}
+	} else {
+		newSharedIP, err := l.client.AddInstanceIPAddress(ctx, ipHolder.ID, true)
+		if err != nil {
+			return "", err
+		}
+		sharedIP = newSharedIP.Address
+	}
+
+	addrs := []string{sharedIP}
 	for _, i := range inClusterAddrs {
 		if slices.Contains(ipHolderAddrs, i) {
 			addrs = append(addrs, i)
@@ -260,7 +274,7 @@ func (l *loadbalancers) createSharedIP(ctx context.Context, nodes []*v1.Node, ip
 		}
 	}
 
-	return newSharedIP.Address, nil
+	return sharedIP, nil
 }
 
 // deleteSharedIP cleans up the shared IP for a LoadBalancer Service if it was assigned
@@ -306,6 +320,13 @@ func (l *loadbalancers) deleteSharedIP(ctx context.Context, service *v1.Service)
 				}
 			}
 
+			// keep requested IPs on the ip-holder, so that they can be
+			// assigned again when the Service is recreated
+			if ingress.IP == getRequestedLoadBalancerIP(service) {
+				klog.Infof("keeping requested IP %s of Service %s on the ip-holder", ingress.IP, serviceNn)
+				continue
+			}
+
 			// finally delete the shared IP on the ip-holder
 			err = l.client.DeleteInstanceIPAddress(ctx, ipHolder.ID, ingress.IP)
 			if IgnoreLinodeAPIError(err, http.StatusNotFound) != nil {
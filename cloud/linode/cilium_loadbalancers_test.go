@@ -3,6 +3,7 @@ package linode
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"testing"
@@ -10,6 +11,7 @@ import (
 	k8sClient "github.com/cilium/cilium/pkg/k8s/client"
 	fakev2alpha1 "github.com/cilium/cilium/pkg/k8s/client/clientset/versioned/typed/cilium.io/v2alpha1/fake"
 	"github.com/golang/mock/gomock"
+	"github.com/linode/linode-cloud-controller-manager/cloud/annotations"
 	"github.com/linode/linode-cloud-controller-manager/cloud/linode/client/mocks"
 	"github.com/linode/linodego"
 	v1 "k8s.io/api/core/v1"
@@ -117,6 +119,18 @@ func TestCiliumCCMLoadBalancers(t *testing.T) {
 			name: "Create Cilium Load Balancer With no existing IP holder nanode and 63 char long suffix",
 			f:    testCreateWithNoExistingIPHolderUsingLongSuffix,
 		},
+		{
+			name: "Create Cilium Load Balancer With requested IP held by the IP holder",
+			f:    testCreateWithRequestedIP,
+		},
+		{
+			name: "Create Cilium Load Balancer With requested IP not held by the IP holder",
+			f:    testCreateWithUnavailableRequestedIP,
+		},
+		{
+			name: "Delete Cilium Load Balancer With requested IP",
+			f:    testEnsureCiliumLoadBalancerDeletedWithRequestedIP,
+		},
 		{
 			name: "Delete Cilium Load Balancer With Old IP Holder Naming Convention",
 			f:    testEnsureCiliumLoadBalancerDeletedWithOldIpHolderNamingConvention,
@@ -209,7 +223,7 @@ func testNoBGPNodeLabel(t *testing.T, mc *mocks.MockClient) {
 	ciliumClient := &fakev2alpha1.FakeCiliumV2alpha1{Fake: &kubeClient.CiliumFakeClientset.Fake}
 	addService(t, kubeClient, svc)
 	addNodes(t, kubeClient, nodes)
-	lb := &loadbalancers{mc, zone, kubeClient, ciliumClient, ciliumLBType}
+	lb := &loadbalancers{client: mc, zone: zone, kubeClient: kubeClient, ciliumClient: ciliumClient, loadBalancerType: ciliumLBType}
 
 	filter := map[string]string{"label": fmt.Sprintf("%s-%s", ipHolderLabelPrefix, zone)}
 	rawFilter, _ := json.Marshal(filter)
@@ -254,7 +268,7 @@ func testUnsupportedRegion(t *testing.T, mc *mocks.MockClient) {
 	kubeClient, _ := k8sClient.NewFakeClientset()
 	ciliumClient := &fakev2alpha1.FakeCiliumV2alpha1{Fake: &kubeClient.CiliumFakeClientset.Fake}
 	addService(t, kubeClient, svc)
-	lb := &loadbalancers{mc, "us-foobar", kubeClient, ciliumClient, ciliumLBType}
+	lb := &loadbalancers{client: mc, zone: "us-foobar", kubeClient: kubeClient, ciliumClient: ciliumClient, loadBalancerType: ciliumLBType}
 
 	lbStatus, err := lb.EnsureLoadBalancer(context.TODO(), "linodelb", svc, nodes)
 	if err == nil {
@@ -266,7 +280,7 @@ func testUnsupportedRegion(t *testing.T, mc *mocks.MockClient) {
 
 	// Use BGP custom id map
 	t.Setenv("BGP_CUSTOM_ID_MAP", "{'us-foobar': 2}")
-	lb = &loadbalancers{mc, zone, kubeClient, ciliumClient, ciliumLBType}
+	lb = &loadbalancers{client: mc, zone: zone, kubeClient: kubeClient, ciliumClient: ciliumClient, loadBalancerType: ciliumLBType}
 	lbStatus, err = lb.EnsureLoadBalancer(context.TODO(), "linodelb", svc, nodes)
 	if err == nil {
 		t.Fatal("expected not nil error")
@@ -285,7 +299,7 @@ func testCreateWithExistingIPHolderWithOldIpHolderNamingConvention(t *testing.T,
 	ciliumClient := &fakev2alpha1.FakeCiliumV2alpha1{Fake: &kubeClient.CiliumFakeClientset.Fake}
 	addService(t, kubeClient, svc)
 	addNodes(t, kubeClient, nodes)
-	lb := &loadbalancers{mc, zone, kubeClient, ciliumClient, ciliumLBType}
+	lb := &loadbalancers{client: mc, zone: zone, kubeClient: kubeClient, ciliumClient: ciliumClient, loadBalancerType: ciliumLBType}
 
 	filter := map[string]string{"label": fmt.Sprintf("%s-%s", ipHolderLabelPrefix, zone)}
 	rawFilter, _ := json.Marshal(filter)
@@ -325,7 +339,7 @@ func testCreateWithExistingIPHolderWithNewIpHolderNamingConvention(t *testing.T,
 	ciliumClient := &fakev2alpha1.FakeCiliumV2alpha1{Fake: &kubeClient.CiliumFakeClientset.Fake}
 	addService(t, kubeClient, svc)
 	addNodes(t, kubeClient, nodes)
-	lb := &loadbalancers{mc, zone, kubeClient, ciliumClient, ciliumLBType}
+	lb := &loadbalancers{client: mc, zone: zone, kubeClient: kubeClient, ciliumClient: ciliumClient, loadBalancerType: ciliumLBType}
 
 	filter := map[string]string{"label": fmt.Sprintf("%s-%s", ipHolderLabelPrefix, zone)}
 	rawFilter, _ := json.Marshal(filter)
@@ -365,7 +379,7 @@ func testCreateWithExistingIPHolderWithNewIpHolderNamingConventionUsingLongSuffi
 	ciliumClient := &fakev2alpha1.FakeCiliumV2alpha1{Fake: &kubeClient.CiliumFakeClientset.Fake}
 	addService(t, kubeClient, svc)
 	addNodes(t, kubeClient, nodes)
-	lb := &loadbalancers{mc, zone, kubeClient, ciliumClient, ciliumLBType}
+	lb := &loadbalancers{client: mc, zone: zone, kubeClient: kubeClient, ciliumClient: ciliumClient, loadBalancerType: ciliumLBType}
 
 	filter := map[string]string{"label": fmt.Sprintf("%s-%s", ipHolderLabelPrefix, zone)}
 	rawFilter, _ := json.Marshal(filter)
@@ -405,7 +419,7 @@ func testCreateWithNoExistingIPHolderUsingNoSuffix(t *testing.T, mc *mocks.MockC
 	ciliumClient := &fakev2alpha1.FakeCiliumV2alpha1{Fake: &kubeClient.CiliumFakeClientset.Fake}
 	addService(t, kubeClient, svc)
 	addNodes(t, kubeClient, nodes)
-	lb := &loadbalancers{mc, zone, kubeClient, ciliumClient, ciliumLBType}
+	lb := &loadbalancers{client: mc, zone: zone, kubeClient: kubeClient, ciliumClient: ciliumClient, loadBalancerType: ciliumLBType}
 
 	filter := map[string]string{"label": fmt.Sprintf("%s-%s", ipHolderLabelPrefix, zone)}
 	rawFilter, _ := json.Marshal(filter)
@@ -449,7 +463,7 @@ func testCreateWithNoExistingIPHolderUsingShortSuffix(t *testing.T, mc *mocks.Mo
 	ciliumClient := &fakev2alpha1.FakeCiliumV2alpha1{Fake: &kubeClient.CiliumFakeClientset.Fake}
 	addService(t, kubeClient, svc)
 	addNodes(t, kubeClient, nodes)
-	lb := &loadbalancers{mc, zone, kubeClient, ciliumClient, ciliumLBType}
+	lb := &loadbalancers{client: mc, zone: zone, kubeClient: kubeClient, ciliumClient: ciliumClient, loadBalancerType: ciliumLBType}
 
 	filter := map[string]string{"label": fmt.Sprintf("%s-%s", ipHolderLabelPrefix, zone)}
 	rawFilter, _ := json.Marshal(filter)
@@ -493,7 +507,7 @@ func testCreateWithNoExistingIPHolderUsingLongSuffix(t *testing.T, mc *mocks.Moc
 	ciliumClient := &fakev2alpha1.FakeCiliumV2alpha1{Fake: &kubeClient.CiliumFakeClientset.Fake}
 	addService(t, kubeClient, svc)
 	addNodes(t, kubeClient, nodes)
-	lb := &loadbalancers{mc, zone, kubeClient, ciliumClient, ciliumLBType}
+	lb := &loadbalancers{client: mc, zone: zone, kubeClient: kubeClient, ciliumClient: ciliumClient, loadBalancerType: ciliumLBType}
 
 	filter := map[string]string{"label": fmt.Sprintf("%s-%s", ipHolderLabelPrefix, zone)}
 	rawFilter, _ := json.Marshal(filter)
@@ -535,7 +549,7 @@ func testEnsureCiliumLoadBalancerDeletedWithOldIpHolderNamingConvention(t *testi
 	ciliumClient := &fakev2alpha1.FakeCiliumV2alpha1{Fake: &kubeClient.CiliumFakeClientset.Fake}
 	addService(t, kubeClient, svc)
 	addNodes(t, kubeClient, nodes)
-	lb := &loadbalancers{mc, zone, kubeClient, ciliumClient, ciliumLBType}
+	lb := &loadbalancers{client: mc, zone: zone, kubeClient: kubeClient, ciliumClient: ciliumClient, loadBalancerType: ciliumLBType}
 
 	dummySharedIP := "45.76.101.26"
 	svc.Status.LoadBalancer = v1.LoadBalancerStatus{Ingress: []v1.LoadBalancerIngress{{IP: dummySharedIP}}}
@@ -563,7 +577,7 @@ func testEnsureCiliumLoadBalancerDeletedWithNewIpHolderNamingConvention(t *testi
 	ciliumClient := &fakev2alpha1.FakeCiliumV2alpha1{Fake: &kubeClient.CiliumFakeClientset.Fake}
 	addService(t, kubeClient, svc)
 	addNodes(t, kubeClient, nodes)
-	lb := &loadbalancers{mc, zone, kubeClient, ciliumClient, ciliumLBType}
+	lb := &loadbalancers{client: mc, zone: zone, kubeClient: kubeClient, ciliumClient: ciliumClient, loadBalancerType: ciliumLBType}
 
 	dummySharedIP := "45.76.101.26"
 	svc.Status.LoadBalancer = v1.LoadBalancerStatus{Ingress: []v1.LoadBalancerIngress{{IP: dummySharedIP}}}
@@ -592,7 +606,7 @@ func testCiliumUpdateLoadBalancerAddNodeWithOldIpHolderNamingConvention(t *testi
 	ciliumClient := &fakev2alpha1.FakeCiliumV2alpha1{Fake: &kubeClient.CiliumFakeClientset.Fake}
 	addService(t, kubeClient, svc)
 	addNodes(t, kubeClient, nodes)
-	lb := &loadbalancers{mc, zone, kubeClient, ciliumClient, ciliumLBType}
+	lb := &loadbalancers{client: mc, zone: zone, kubeClient: kubeClient, ciliumClient: ciliumClient, loadBalancerType: ciliumLBType}
 
 	filter := map[string]string{"label": fmt.Sprintf("%s-%s", ipHolderLabelPrefix, zone)}
 	rawFilter, _ := json.Marshal(filter)
@@ -650,7 +664,7 @@ func testCiliumUpdateLoadBalancerAddNodeWithNewIpHolderNamingConvention(t *testi
 	ciliumClient := &fakev2alpha1.FakeCiliumV2alpha1{Fake: &kubeClient.CiliumFakeClientset.Fake}
 	addService(t, kubeClient, svc)
 	addNodes(t, kubeClient, nodes)
-	lb := &loadbalancers{mc, zone, kubeClient, ciliumClient, ciliumLBType}
+	lb := &loadbalancers{client: mc, zone: zone, kubeClient: kubeClient, ciliumClient: ciliumClient, loadBalancerType: ciliumLBType}
 
 	filter := map[string]string{"label": fmt.Sprintf("%s-%s", ipHolderLabelPrefix, zone)}
 	rawFilter, _ := json.Marshal(filter)
@@ -706,3 +720,99 @@ func testCiliumUpdateLoadBalancerAddNodeWithNewIpHolderNamingConvention(t *testi
 		t.Fatalf("expected a nil error, got %v", err)
 	}
 }
+
+func testCreateWithRequestedIP(t *testing.T, mc *mocks.MockClient) {
+	Options.BGPNodeSelector = "cilium-bgp-peering=true"
+	Options.IpHolderSuffix = ""
+	svc := createTestService()
+	reservedIP := "45.76.101.27"
+	svc.Spec.LoadBalancerIP = reservedIP
+
+	kubeClient, _ := k8sClient.NewFakeClientset()
+	ciliumClient := &fakev2alpha1.FakeCiliumV2alpha1{Fake: &kubeClient.CiliumFakeClientset.Fake}
+	addService(t, kubeClient, svc)
+	addNodes(t, kubeClient, nodes)
+	lb := &loadbalancers{client: mc, zone: zone, kubeClient: kubeClient, ciliumClient: ciliumClient, loadBalancerType: ciliumLBType}
+
+	filter := map[string]string{"label": fmt.Sprintf("%s-%s", ipHolderLabelPrefix, zone)}
+	rawFilter, _ := json.Marshal(filter)
+	mc.EXPECT().ListInstances(gomock.Any(), linodego.NewListOptions(1, string(rawFilter))).Times(1).Return([]linodego.Instance{oldIpHolderInstance}, nil)
+	mc.EXPECT().AddInstanceIPAddress(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+	mc.EXPECT().GetInstanceIPAddresses(gomock.Any(), oldIpHolderInstance.ID).Times(1).Return(&linodego.InstanceIPAddressResponse{
+		IPv4: &linodego.InstanceIPv4Response{
+			Public: []*linodego.InstanceIP{{Address: publicIPv4.String()}, {Address: reservedIP}},
+		},
+	}, nil)
+	mc.EXPECT().ShareIPAddresses(gomock.Any(), linodego.IPAddressesShareOptions{
+		IPs:      []string{reservedIP},
+		LinodeID: 11111,
+	}).Times(1)
+	mc.EXPECT().ShareIPAddresses(gomock.Any(), linodego.IPAddressesShareOptions{
+		IPs:      []string{reservedIP},
+		LinodeID: 22222,
+	}).Times(1)
+
+	if _, err := lb.EnsureLoadBalancer(context.TODO(), "linodelb", svc, nodes); err != nil {
+		t.Fatalf("expected a nil error, got %v", err)
+	}
+	pool, err := lb.getCiliumLBIPPool(context.TODO(), svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pool.Spec.Blocks) != 1 || string(pool.Spec.Blocks[0].Cidr) != reservedIP+"/32" {
+		t.Errorf("expected pool for %s, got %v", reservedIP, pool.Spec.Blocks)
+	}
+}
+
+func testCreateWithUnavailableRequestedIP(t *testing.T, mc *mocks.MockClient) {
+	Options.BGPNodeSelector = "cilium-bgp-peering=true"
+	Options.IpHolderSuffix = ""
+	svc := createTestService()
+	svc.Annotations = map[string]string{annotations.AnnLinodeLoadBalancerIP: "45.76.101.27"}
+
+	kubeClient, _ := k8sClient.NewFakeClientset()
+	ciliumClient := &fakev2alpha1.FakeCiliumV2alpha1{Fake: &kubeClient.CiliumFakeClientset.Fake}
+	addService(t, kubeClient, svc)
+	addNodes(t, kubeClient, nodes)
+	lb := &loadbalancers{client: mc, zone: zone, kubeClient: kubeClient, ciliumClient: ciliumClient, loadBalancerType: ciliumLBType}
+
+	filter := map[string]string{"label": fmt.Sprintf("%s-%s", ipHolderLabelPrefix, zone)}
+	rawFilter, _ := json.Marshal(filter)
+	mc.EXPECT().ListInstances(gomock.Any(), linodego.NewListOptions(1, string(rawFilter))).Times(1).Return([]linodego.Instance{oldIpHolderInstance}, nil)
+	mc.EXPECT().GetInstanceIPAddresses(gomock.Any(), oldIpHolderInstance.ID).Times(1).Return(&linodego.InstanceIPAddressResponse{
+		IPv4: &linodego.InstanceIPv4Response{
+			Public: []*linodego.InstanceIP{{Address: publicIPv4.String()}},
+		},
+	}, nil)
+
+	if _, err := lb.EnsureLoadBalancer(context.TODO(), "linodelb", svc, nodes); !errors.Is(err, errLoadBalancerIPUnavailable) {
+		t.Fatalf("expected errLoadBalancerIPUnavailable, got %v", err)
+	}
+}
+
+func testEnsureCiliumLoadBalancerDeletedWithRequestedIP(t *testing.T, mc *mocks.MockClient) {
+	Options.BGPNodeSelector = "cilium-bgp-peering=true"
+	Options.IpHolderSuffix = ""
+	svc := createTestService()
+	reservedIP := "45.76.101.27"
+	svc.Spec.LoadBalancerIP = reservedIP
+
+	kubeClient, _ := k8sClient.NewFakeClientset()
+	ciliumClient := &fakev2alpha1.FakeCiliumV2alpha1{Fake: &kubeClient.CiliumFakeClientset.Fake}
+	addService(t, kubeClient, svc)
+	addNodes(t, kubeClient, nodes)
+	lb := &loadbalancers{client: mc, zone: zone, kubeClient: kubeClient, ciliumClient: ciliumClient, loadBalancerType: ciliumLBType}
+
+	svc.Status.LoadBalancer = v1.LoadBalancerStatus{Ingress: []v1.LoadBalancerIngress{{IP: reservedIP}}}
+
+	filter := map[string]string{"label": fmt.Sprintf("%s-%s", ipHolderLabelPrefix, zone)}
+	rawFilter, _ := json.Marshal(filter)
+	mc.EXPECT().ListInstances(gomock.Any(), linodego.NewListOptions(1, string(rawFilter))).Times(1).Return([]linodego.Instance{oldIpHolderInstance}, nil)
+	mc.EXPECT().DeleteInstanceIPAddress(gomock.Any(), 11111, reservedIP).Times(1).Return(nil)
+	mc.EXPECT().DeleteInstanceIPAddress(gomock.Any(), 22222, reservedIP).Times(1).Return(nil)
+	mc.EXPECT().DeleteInstanceIPAddress(gomock.Any(), oldIpHolderInstance.ID, reservedIP).Times(0)
+
+	if err := lb.EnsureLoadBalancerDeleted(context.TODO(), "linodelb", svc); err != nil {
+		t.Fatalf("expected a nil error, got %v", err)
+	}
+}
@@ -43,6 +43,7 @@ type Client interface {
 	DeleteNodeBalancer(context.Context, int) error
 	ListNodeBalancers(context.Context, *linodego.ListOptions) ([]linodego.NodeBalancer, error)
 	ListNodeBalancerNodes(context.Context, int, int, *linodego.ListOptions) ([]linodego.NodeBalancerNode, error)
+	ListNodeBalancerVPCConfigs(context.Context, int, *linodego.ListOptions) ([]linodego.NodeBalancerVPCConfig, error)
 
 	CreateNodeBalancerConfig(context.Context, int, linodego.NodeBalancerConfigCreateOptions) (*linodego.NodeBalancerConfig, error)
 	DeleteNodeBalancerConfig(context.Context, int, int) error
@@ -53,8 +54,10 @@ type Client interface {
 	DeleteFirewallDevice(ctx context.Context, firewallID, deviceID int) error
 	CreateFirewallDevice(ctx context.Context, firewallID int, opts linodego.FirewallDeviceCreateOptions) (*linodego.FirewallDevice, error)
 	CreateFirewall(ctx context.Context, opts linodego.FirewallCreateOptions) (*linodego.Firewall, error)
+	ListFirewalls(ctx context.Context, opts *linodego.ListOptions) ([]linodego.Firewall, error)
 	DeleteFirewall(ctx context.Context, fwid int) error
 	GetFirewall(context.Context, int) (*linodego.Firewall, error)
+	UpdateFirewall(context.Context, int, linodego.FirewallUpdateOptions) (*linodego.Firewall, error)
 	UpdateFirewallRules(context.Context, int, linodego.FirewallRuleSet) (*linodego.FirewallRuleSet, error)
 
 	GetProfile(ctx context.Context) (*linodego.Profile, error)
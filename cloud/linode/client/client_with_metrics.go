@@ -254,6 +254,19 @@ func (_d ClientWithPrometheus) ListFirewallDevices(ctx context.Context, firewall
 	return _d.base.ListFirewallDevices(ctx, firewallID, opts)
 }
 
+// ListFirewalls implements Client
+func (_d ClientWithPrometheus) ListFirewalls(ctx context.Context, opts *linodego.ListOptions) (fa1 []linodego.Firewall, err error) {
+	defer func() {
+		result := "ok"
+		if err != nil {
+			result = "error"
+		}
+
+		ClientMethodCounterVec.WithLabelValues("ListFirewalls", result).Inc()
+	}()
+	return _d.base.ListFirewalls(ctx, opts)
+}
+
 // ListInstances implements Client
 func (_d ClientWithPrometheus) ListInstances(ctx context.Context, lp1 *linodego.ListOptions) (ia1 []linodego.Instance, err error) {
 	defer func() {
@@ -306,6 +319,19 @@ func (_d ClientWithPrometheus) ListNodeBalancerNodes(ctx context.Context, i1 int
 	return _d.base.ListNodeBalancerNodes(ctx, i1, i2, lp1)
 }
 
+// ListNodeBalancerVPCConfigs implements Client
+func (_d ClientWithPrometheus) ListNodeBalancerVPCConfigs(ctx context.Context, i1 int, lp1 *linodego.ListOptions) (na1 []linodego.NodeBalancerVPCConfig, err error) {
+	defer func() {
+		result := "ok"
+		if err != nil {
+			result = "error"
+		}
+
+		ClientMethodCounterVec.WithLabelValues("ListNodeBalancerVPCConfigs", result).Inc()
+	}()
+	return _d.base.ListNodeBalancerVPCConfigs(ctx, i1, lp1)
+}
+
 // ListNodeBalancers implements Client
 func (_d ClientWithPrometheus) ListNodeBalancers(ctx context.Context, lp1 *linodego.ListOptions) (na1 []linodego.NodeBalancer, err error) {
 	defer func() {
@@ -384,6 +410,19 @@ func (_d ClientWithPrometheus) ShareIPAddresses(ctx context.Context, opts linode
 	return _d.base.ShareIPAddresses(ctx, opts)
 }
 
+// UpdateFirewall implements Client
+func (_d ClientWithPrometheus) UpdateFirewall(ctx context.Context, i1 int, f1 linodego.FirewallUpdateOptions) (fp1 *linodego.Firewall, err error) {
+	defer func() {
+		result := "ok"
+		if err != nil {
+			result = "error"
+		}
+
+		ClientMethodCounterVec.WithLabelValues("UpdateFirewall", result).Inc()
+	}()
+	return _d.base.UpdateFirewall(ctx, i1, f1)
+}
+
 // UpdateFirewallRules implements Client
 func (_d ClientWithPrometheus) UpdateFirewallRules(ctx context.Context, i1 int, f1 linodego.FirewallRuleSet) (fp1 *linodego.FirewallRuleSet, err error) {
 	defer func() {
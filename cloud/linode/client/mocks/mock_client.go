@@ -285,6 +285,21 @@ func (mr *MockClientMockRecorder) ListFirewallDevices(arg0, arg1, arg2 interface
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListFirewallDevices", reflect.TypeOf((*MockClient)(nil).ListFirewallDevices), arg0, arg1, arg2)
 }
 
+// ListFirewalls mocks base method.
+func (m *MockClient) ListFirewalls(arg0 context.Context, arg1 *linodego.ListOptions) ([]linodego.Firewall, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListFirewalls", arg0, arg1)
+	ret0, _ := ret[0].([]linodego.Firewall)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListFirewalls indicates an expected call of ListFirewalls.
+func (mr *MockClientMockRecorder) ListFirewalls(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListFirewalls", reflect.TypeOf((*MockClient)(nil).ListFirewalls), arg0, arg1)
+}
+
 // ListInstances mocks base method.
 func (m *MockClient) ListInstances(arg0 context.Context, arg1 *linodego.ListOptions) ([]linodego.Instance, error) {
 	m.ctrl.T.Helper()
@@ -345,6 +360,21 @@ func (mr *MockClientMockRecorder) ListNodeBalancerNodes(arg0, arg1, arg2, arg3 i
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListNodeBalancerNodes", reflect.TypeOf((*MockClient)(nil).ListNodeBalancerNodes), arg0, arg1, arg2, arg3)
 }
 
+// ListNodeBalancerVPCConfigs mocks base method.
+func (m *MockClient) ListNodeBalancerVPCConfigs(arg0 context.Context, arg1 int, arg2 *linodego.ListOptions) ([]linodego.NodeBalancerVPCConfig, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListNodeBalancerVPCConfigs", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]linodego.NodeBalancerVPCConfig)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListNodeBalancerVPCConfigs indicates an expected call of ListNodeBalancerVPCConfigs.
+func (mr *MockClientMockRecorder) ListNodeBalancerVPCConfigs(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListNodeBalancerVPCConfigs", reflect.TypeOf((*MockClient)(nil).ListNodeBalancerVPCConfigs), arg0, arg1, arg2)
+}
+
 // ListNodeBalancers mocks base method.
 func (m *MockClient) ListNodeBalancers(arg0 context.Context, arg1 *linodego.ListOptions) ([]linodego.NodeBalancer, error) {
 	m.ctrl.T.Helper()
@@ -434,6 +464,21 @@ func (mr *MockClientMockRecorder) ShareIPAddresses(arg0, arg1 interface{}) *gomo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ShareIPAddresses", reflect.TypeOf((*MockClient)(nil).ShareIPAddresses), arg0, arg1)
 }
 
+// UpdateFirewall mocks base method.
+func (m *MockClient) UpdateFirewall(arg0 context.Context, arg1 int, arg2 linodego.FirewallUpdateOptions) (*linodego.Firewall, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateFirewall", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*linodego.Firewall)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateFirewall indicates an expected call of UpdateFirewall.
+func (mr *MockClientMockRecorder) UpdateFirewall(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateFirewall", reflect.TypeOf((*MockClient)(nil).UpdateFirewall), arg0, arg1, arg2)
+}
+
 // UpdateFirewallRules mocks base method.
 func (m *MockClient) UpdateFirewallRules(arg0 context.Context, arg1 int, arg2 linodego.FirewallRuleSet) (*linodego.FirewallRuleSet, error) {
 	m.ctrl.T.Helper()
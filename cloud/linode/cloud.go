@@ -5,17 +5,26 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"net/url"
 	"os"
 	"strconv"
 	"time"
 
+	"github.com/linode/linodego"
 	"github.com/spf13/pflag"
 	"golang.org/x/exp/slices"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
 	cloudprovider "k8s.io/cloud-provider"
 	"k8s.io/klog/v2"
 
 	"github.com/linode/linode-cloud-controller-manager/cloud/linode/client"
+	"github.com/linode/linode-cloud-controller-manager/cloud/linode/firewall"
 )
 
 const (
@@ -39,15 +48,42 @@ var Options struct {
 	EnableRouteController    bool
 	EnableTokenHealthChecker bool
 	// Deprecated: use VPCNames instead
-	VPCName               string
-	VPCNames              string
-	SubnetNames           string
-	LoadBalancerType      string
-	BGPNodeSelector       string
-	IpHolderSuffix        string
-	LinodeExternalNetwork *net.IPNet
-	NodeBalancerTags      []string
-	GlobalStopChannel     chan<- struct{}
+	VPCName                       string
+	VPCNames                      string
+	SubnetNames                   string
+	EnableNodeBalancerVPCBackends bool
+	LoadBalancerType              string
+	BGPNodeSelector               string
+	IpHolderSuffix                string
+	LinodeExternalNetwork         *net.IPNet
+	NodeBalancerTags              []string
+	GlobalStopChannel             chan<- struct{}
+	// ClusterName is the --cluster-name of the CCM, which NodeBalancers are tagged with
+	ClusterName               string
+	EnableNodeBalancerGC      bool
+	NodeBalancerGCInterval    time.Duration
+	NodeBalancerGCGracePeriod time.Duration
+	NodeBalancerGCDryRun      bool
+	// AdmissionWebhookAddress enables the Service validation webhook when set
+	AdmissionWebhookAddress string
+	AdmissionWebhookCertDir string
+	// LoadBalancerClasses maps the load balancer classes handled by the CCM to
+	// the type of load balancer their Services get
+	LoadBalancerClasses map[string]string
+	// Defaults of the NodeBalancer settings of Services that do not set them
+	// by annotation
+	DefaultCheckInterval    int
+	DefaultCheckTimeout     int
+	DefaultCheckAttempts    int
+	DefaultCheckPassive     bool
+	DefaultProtocol         string
+	DefaultProxyProtocol    string
+	DefaultThrottle         int
+	DefaultNodeBalancerTags []string
+	// IPFeeds maps the names of the IP feeds firewall ACLs can reference, on
+	// top of the firewall.DefaultIPFeeds, to their URL
+	IPFeeds               map[string]string
+	IPFeedRefreshInterval time.Duration
 }
 
 type linodeCloud struct {
@@ -56,12 +92,20 @@ type linodeCloud struct {
 	loadbalancers            cloudprovider.LoadBalancer
 	routes                   cloudprovider.Routes
 	linodeTokenHealthChecker *healthChecker
+	ipFeeds                  *firewall.IPFeeds
 }
 
 var instanceCache *instances
 
 func init() {
 	registerMetrics()
+	// the flags of the CCM default to these too
+	Options.DefaultCheckInterval = 5
+	Options.DefaultCheckTimeout = 3
+	Options.DefaultCheckAttempts = 2
+	Options.DefaultCheckPassive = true
+	Options.DefaultProtocol = string(linodego.ProtocolTCP)
+	Options.DefaultProxyProtocol = string(linodego.ProxyProtocolNone)
 	cloudprovider.RegisterCloudProvider(
 		ProviderName,
 		func(io.Reader) (cloudprovider.Interface, error) {
@@ -139,8 +183,12 @@ func newCloud() (cloudprovider.Interface, error) {
 		Options.SubnetNames = ""
 	}
 
+	if Options.EnableNodeBalancerVPCBackends && Options.VPCNames == "" {
+		return nil, fmt.Errorf("cannot enable nodebalancer vpc backends as vpc-names is empty")
+	}
+
 	instanceCache = newInstances(linodeClient)
-	routes, err := newRoutes(linodeClient, instanceCache)
+	linodeRoutes, err := newRoutes(linodeClient, instanceCache)
 	if err != nil {
 		return nil, fmt.Errorf("routes client was not created successfully: %w", err)
 	}
@@ -153,6 +201,27 @@ func newCloud() (cloudprovider.Interface, error) {
 		)
 	}
 
+	for class, lbType := range Options.LoadBalancerClasses {
+		if !slices.Contains(supportedLoadBalancerTypes, lbType) {
+			return nil, fmt.Errorf(
+				"unsupported load-balancer type %s of load balancer class %s. Options are %v",
+				lbType,
+				class,
+				supportedLoadBalancerTypes,
+			)
+		}
+	}
+
+	if err = validateNodeBalancerDefaults(); err != nil {
+		return nil, err
+	}
+
+	if Options.EnableNodeBalancerGC {
+		if err = validateGCClusterName(Options.ClusterName); err != nil {
+			return nil, err
+		}
+	}
+
 	if Options.IpHolderSuffix != "" {
 		klog.Infof("Using IP holder suffix '%s'\n", Options.IpHolderSuffix)
 	}
@@ -163,13 +232,31 @@ func newCloud() (cloudprovider.Interface, error) {
 		return nil, fmt.Errorf("%s", msg)
 	}
 
+	ipFeeds, err := newIPFeeds()
+	if err != nil {
+		return nil, err
+	}
+
+	// the webhook must be served by every replica, so it cannot be started
+	// along with the controllers, which only run on the leader
+	if Options.AdmissionWebhookAddress != "" {
+		go newAdmissionWebhook(Options.AdmissionWebhookAddress, Options.AdmissionWebhookCertDir, ipFeeds).Run()
+	}
+
+	lb := newLoadbalancers(linodeClient, region).(*loadbalancers)
+	lb.ipFeeds = ipFeeds
+	// NodeBalancers with VPC backends find the nodes in the VPC IPs cached
+	// for the route controller
+	lb.routeCache = linodeRoutes.(*routes).routeCache
+
 	// create struct that satisfies cloudprovider.Interface
 	lcloud := &linodeCloud{
 		client:                   linodeClient,
 		instances:                instanceCache,
-		loadbalancers:            newLoadbalancers(linodeClient, region),
-		routes:                   routes,
+		loadbalancers:            lb,
+		routes:                   linodeRoutes,
 		linodeTokenHealthChecker: healthChecker,
+		ipFeeds:                  ipFeeds,
 	}
 	return lcloud, nil
 }
@@ -184,11 +271,68 @@ func (c *linodeCloud) Initialize(clientBuilder cloudprovider.ControllerClientBui
 		go c.linodeTokenHealthChecker.Run(stopCh)
 	}
 
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: kubeclient.CoreV1().Events("")})
+	c.loadbalancers.(*loadbalancers).recorder = eventBroadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "linode-cloud-controller-manager"})
+
 	serviceController := newServiceController(c.loadbalancers.(*loadbalancers), serviceInformer)
 	go serviceController.Run(stopCh)
 
 	nodeController := newNodeController(kubeclient, c.client, nodeInformer, instanceCache)
 	go nodeController.Run(stopCh)
+
+	// Services can ask for a NodeBalancer whatever the default load balancer
+	// type is
+	endpointSliceInformer := sharedInformer.Discovery().V1().EndpointSlices()
+	endpointSliceController := newEndpointSliceController(c.loadbalancers.(*loadbalancers), endpointSliceInformer, serviceInformer, nodeInformer)
+	go endpointSliceController.Run(stopCh)
+
+	// only TLS Secrets can be referenced by NodeBalancer ports, so avoid
+	// caching every other Secret in the cluster
+	secretInformer := informers.NewSharedInformerFactoryWithOptions(kubeclient, 0,
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.FieldSelector = fields.OneTermEqualSelector("type", string(v1.SecretTypeTLS)).String()
+		}),
+	).Core().V1().Secrets()
+	tlsSecretController := newTLSSecretController(c.loadbalancers.(*loadbalancers), secretInformer, serviceInformer, nodeInformer)
+	go tlsSecretController.Run(stopCh)
+
+	configMapInformer := sharedInformer.Core().V1().ConfigMaps()
+	firewallACLController := newFirewallACLController(c.loadbalancers.(*loadbalancers), configMapInformer, serviceInformer, c.ipFeeds)
+	go firewallACLController.Run(stopCh)
+	go c.ipFeeds.Run(stopCh, Options.IPFeedRefreshInterval)
+
+	// the cloud-provider service controller ignores Services with a load
+	// balancer class
+	if len(Options.LoadBalancerClasses) > 0 {
+		loadBalancerClassController := newLoadBalancerClassController(c.loadbalancers.(*loadbalancers), serviceInformer, nodeInformer)
+		go loadBalancerClassController.Run(stopCh)
+	}
+
+	if Options.EnableNodeBalancerGC {
+		garbageCollector := newGarbageCollector(c.client, serviceInformer)
+		go garbageCollector.Run(stopCh)
+	}
+}
+
+// newIPFeeds returns the IP feeds firewall ACLs can reference, the
+// firewall.DefaultIPFeeds and the --firewall-ip-feeds.
+func newIPFeeds() (*firewall.IPFeeds, error) {
+	if Options.IPFeedRefreshInterval <= 0 {
+		Options.IPFeedRefreshInterval = time.Hour
+	}
+	feeds := make(map[string]firewall.IPFeed)
+	for name, feedURL := range firewall.DefaultIPFeeds {
+		feeds[name] = &firewall.HTTPFeed{URL: feedURL}
+	}
+	for name, feedURL := range Options.IPFeeds {
+		parsed, err := url.Parse(feedURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return nil, fmt.Errorf("invalid URL %q of firewall IP feed %s: must be an http or https URL", feedURL, name)
+		}
+		feeds[name] = &firewall.HTTPFeed{URL: feedURL}
+	}
+	return firewall.NewIPFeeds(feeds), nil
 }
 
 func (c *linodeCloud) LoadBalancer() (cloudprovider.LoadBalancer, bool) {
@@ -229,3 +373,33 @@ func (c *linodeCloud) ScrubDNS(_, _ []string) (nsOut, srchOut []string) {
 func (c *linodeCloud) HasClusterID() bool {
 	return true
 }
+
+// validateNodeBalancerDefaults checks the defaults of the NodeBalancer
+// settings against the values the annotations accept.
+func validateNodeBalancerDefaults() error {
+	for _, check := range []struct {
+		flag               string
+		value              int
+		minValue, maxValue int
+	}{
+		{"default-check-interval", Options.DefaultCheckInterval, 2, 3600},
+		{"default-check-timeout", Options.DefaultCheckTimeout, 1, 30},
+		{"default-check-attempts", Options.DefaultCheckAttempts, 1, 30},
+		{"default-throttle", Options.DefaultThrottle, 0, maxConnThrottle},
+	} {
+		if check.value < check.minValue || check.value > check.maxValue {
+			return fmt.Errorf("%s must be between %d and %d, got %d", check.flag, check.minValue, check.maxValue, check.value)
+		}
+	}
+	switch linodego.ConfigProtocol(Options.DefaultProtocol) {
+	case linodego.ProtocolTCP, linodego.ProtocolHTTP, linodego.ProtocolHTTPS:
+	default:
+		return fmt.Errorf("unsupported default-protocol %s. Options are tcp, http, https", Options.DefaultProtocol)
+	}
+	switch linodego.ConfigProxyProtocol(Options.DefaultProxyProtocol) {
+	case linodego.ProxyProtocolNone, linodego.ProxyProtocolV1, linodego.ProxyProtocolV2:
+	default:
+		return fmt.Errorf("unsupported default-proxy-protocol %s. Options are none, v1, v2", Options.DefaultProxyProtocol)
+	}
+	return nil
+}
@@ -90,6 +90,38 @@ func TestNewCloud(t *testing.T) {
 		assert.Error(t, err, "expected error if incorrect loadbalancertype is set")
 	})
 
+	t.Run("should fail if a nodebalancer default is out of range", func(t *testing.T) {
+		timeout := Options.DefaultCheckTimeout
+		Options.DefaultCheckTimeout = 31
+		defer func() {
+			Options.DefaultCheckTimeout = timeout
+		}()
+		_, err := newCloud()
+		assert.Error(t, err, "expected error if default-check-timeout is out of range")
+	})
+
+	t.Run("should fail if an unsupported default protocol is set", func(t *testing.T) {
+		protocol := Options.DefaultProtocol
+		Options.DefaultProtocol = "udp"
+		defer func() {
+			Options.DefaultProtocol = protocol
+		}()
+		_, err := newCloud()
+		assert.Error(t, err, "expected error if default-protocol is udp")
+	})
+
+	t.Run("should fail if nodebalancer gc is enabled with the default cluster name", func(t *testing.T) {
+		enabled, clusterName := Options.EnableNodeBalancerGC, Options.ClusterName
+		Options.EnableNodeBalancerGC = true
+		Options.ClusterName = "kubernetes"
+		defer func() {
+			Options.EnableNodeBalancerGC = enabled
+			Options.ClusterName = clusterName
+		}()
+		_, err := newCloud()
+		assert.Error(t, err, "expected error if nodebalancer gc is enabled with the default cluster name")
+	})
+
 	t.Run("should fail if ipholdersuffix is longer than 23 chars", func(t *testing.T) {
 		suffix := Options.IpHolderSuffix
 		Options.IpHolderSuffix = strings.Repeat("a", 24)
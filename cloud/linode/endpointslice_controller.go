@@ -0,0 +1,133 @@
+package linode
+
+import (
+	"context"
+	"time"
+
+	"github.com/appscode/go/wait"
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	v1informers "k8s.io/client-go/informers/core/v1"
+	discoveryinformers "k8s.io/client-go/informers/discovery/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+)
+
+// endpointSliceUpdateDelay batches the EndpointSlice updates of a rollout
+// into a single NodeBalancer update.
+var endpointSliceUpdateDelay = 5 * time.Second
+
+// endpointSliceController updates the NodeBalancer of Services with the Local
+// external traffic policy when the nodes hosting their ready endpoints change.
+// The cloud-provider service controller only reacts to Service and Node
+// changes, so it would otherwise keep sending traffic to nodes without pods.
+type endpointSliceController struct {
+	loadbalancers   *loadbalancers
+	informer        discoveryinformers.EndpointSliceInformer
+	serviceInformer v1informers.ServiceInformer
+	nodeInformer    v1informers.NodeInformer
+
+	queue workqueue.TypedDelayingInterface[any]
+}
+
+func newEndpointSliceController(
+	loadbalancers *loadbalancers,
+	informer discoveryinformers.EndpointSliceInformer,
+	serviceInformer v1informers.ServiceInformer,
+	nodeInformer v1informers.NodeInformer,
+) *endpointSliceController {
+	loadbalancers.endpointSlices = informer.Lister()
+	return &endpointSliceController{
+		loadbalancers:   loadbalancers,
+		informer:        informer,
+		serviceInformer: serviceInformer,
+		nodeInformer:    nodeInformer,
+		queue:           workqueue.NewTypedDelayingQueueWithConfig[any](workqueue.TypedDelayingQueueConfig[any]{Name: "ccm_endpointslice"}),
+	}
+}
+
+func (s *endpointSliceController) Run(stopCh <-chan struct{}) {
+	enqueue := func(obj interface{}) {
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		slice, ok := obj.(*discoveryv1.EndpointSlice)
+		if !ok {
+			return
+		}
+		name, ok := slice.Labels[discoveryv1.LabelServiceName]
+		if !ok {
+			return
+		}
+		s.queue.AddAfter(slice.Namespace+"/"+name, endpointSliceUpdateDelay)
+	}
+	if _, err := s.informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    enqueue,
+		UpdateFunc: func(_, newObj interface{}) { enqueue(newObj) },
+		DeleteFunc: enqueue,
+	}); err != nil {
+		klog.Errorf("EndpointSliceController didn't successfully register it's Informer %s", err)
+	}
+
+	go s.informer.Informer().Run(stopCh)
+	if !cache.WaitForCacheSync(stopCh, s.informer.Informer().HasSynced, s.serviceInformer.Informer().HasSynced, s.nodeInformer.Informer().HasSynced) {
+		klog.Errorf("EndpointSliceController failed to sync its informers")
+		return
+	}
+
+	wait.Until(s.worker, time.Second, stopCh)
+}
+
+// worker runs a worker thread that dequeues Services whose endpoints changed
+// and updates their NodeBalancer.
+func (s *endpointSliceController) worker() {
+	for s.processNext() {
+	}
+}
+
+func (s *endpointSliceController) processNext() bool {
+	key, quit := s.queue.Get()
+	if quit {
+		return false
+	}
+	defer s.queue.Done(key)
+
+	name, ok := key.(string)
+	if !ok {
+		klog.Errorf("expected dequeued key to be of type string but got %T", key)
+		return true
+	}
+
+	if err := s.handleEndpointsChanged(name); err != nil {
+		klog.Errorf("failed to update NodeBalancer for service (%s) endpoints; retrying in 1 minute: %s", name, err)
+		s.queue.AddAfter(name, retryInterval)
+	}
+	return true
+}
+
+func (s *endpointSliceController) handleEndpointsChanged(key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+	service, err := s.serviceInformer.Lister().Services(namespace).Get(name)
+	if err != nil {
+		// deleted Services are handled by the service controller
+		return nil
+	}
+	if service.Spec.Type != v1.ServiceTypeLoadBalancer ||
+		service.Spec.ExternalTrafficPolicy != v1.ServiceExternalTrafficPolicyLocal ||
+		len(service.Status.LoadBalancer.Ingress) == 0 ||
+		!s.loadbalancers.usesNodeBalancer(service) {
+		return nil
+	}
+
+	nodes, err := getLoadBalancerNodes(s.nodeInformer.Lister())
+	if err != nil {
+		return err
+	}
+
+	klog.Infof("EndpointSliceController updating NodeBalancer for service (%s) endpoints", key)
+	return s.loadbalancers.UpdateLoadBalancer(context.Background(), Options.ClusterName, service, nodes)
+}
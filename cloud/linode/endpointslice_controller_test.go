@@ -0,0 +1,131 @@
+package linode
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
+
+	"github.com/linode/linode-cloud-controller-manager/cloud/linode/client/mocks"
+)
+
+func newTestEndpointSlice(service string, ready map[string]*bool) *discoveryv1.EndpointSlice {
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      service + "-abcde",
+			Namespace: "default",
+			Labels:    map[string]string{discoveryv1.LabelServiceName: service},
+		},
+	}
+	for node, isReady := range ready {
+		slice.Endpoints = append(slice.Endpoints, discoveryv1.Endpoint{
+			NodeName:   ptr.To(node),
+			Conditions: discoveryv1.EndpointConditions{Ready: isReady},
+		})
+	}
+	return slice
+}
+
+func Test_getLocalTrafficNodes(t *testing.T) {
+	nodes := []*v1.Node{
+		{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "node-2"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "node-3"}},
+	}
+
+	testcases := []struct {
+		name     string
+		policy   v1.ServiceExternalTrafficPolicy
+		slice    *discoveryv1.EndpointSlice
+		expected []string
+	}{
+		{
+			name:     "cluster traffic policy",
+			policy:   v1.ServiceExternalTrafficPolicyCluster,
+			slice:    newTestEndpointSlice("svc", map[string]*bool{"node-1": ptr.To(true)}),
+			expected: []string{"node-1", "node-2", "node-3"},
+		},
+		{
+			name:     "local traffic policy",
+			policy:   v1.ServiceExternalTrafficPolicyLocal,
+			slice:    newTestEndpointSlice("svc", map[string]*bool{"node-1": ptr.To(true), "node-2": nil, "node-3": ptr.To(false)}),
+			expected: []string{"node-1", "node-2"},
+		},
+		{
+			name:     "local traffic policy without ready endpoints",
+			policy:   v1.ServiceExternalTrafficPolicyLocal,
+			slice:    newTestEndpointSlice("svc", map[string]*bool{"node-1": ptr.To(false)}),
+			expected: []string{"node-1", "node-2", "node-3"},
+		},
+		{
+			name:     "local traffic policy with endpoints of another service",
+			policy:   v1.ServiceExternalTrafficPolicyLocal,
+			slice:    newTestEndpointSlice("other", map[string]*bool{"node-1": ptr.To(true)}),
+			expected: []string{"node-1", "node-2", "node-3"},
+		},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.name, func(t *testing.T) {
+			informer := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0).Discovery().V1().EndpointSlices()
+			assert.NoError(t, informer.Informer().GetIndexer().Add(test.slice))
+			lb := &loadbalancers{endpointSlices: informer.Lister()}
+
+			service := &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{Name: "svc", Namespace: "default"},
+				Spec:       v1.ServiceSpec{ExternalTrafficPolicy: test.policy},
+			}
+			var names []string
+			for _, node := range lb.getLocalTrafficNodes(service, nodes) {
+				names = append(names, node.Name)
+			}
+			assert.ElementsMatch(t, test.expected, names)
+		})
+	}
+}
+
+func Test_endpointSliceController_handleEndpointsChanged(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	// no NodeBalancer is updated for these services, so the client must not be called
+	client := mocks.NewMockClient(ctrl)
+
+	factory := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
+	serviceInformer := factory.Core().V1().Services()
+	lb := newLoadbalancers(client, "us-east").(*loadbalancers)
+	controller := newEndpointSliceController(lb, factory.Discovery().V1().EndpointSlices(), serviceInformer, factory.Core().V1().Nodes())
+
+	for _, service := range []*v1.Service{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "default"},
+			Spec: v1.ServiceSpec{
+				Type:                  v1.ServiceTypeLoadBalancer,
+				ExternalTrafficPolicy: v1.ServiceExternalTrafficPolicyCluster,
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "pending", Namespace: "default"},
+			Spec: v1.ServiceSpec{
+				Type:                  v1.ServiceTypeLoadBalancer,
+				ExternalTrafficPolicy: v1.ServiceExternalTrafficPolicyLocal,
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "nodeport", Namespace: "default"},
+			Spec:       v1.ServiceSpec{Type: v1.ServiceTypeNodePort},
+		},
+	} {
+		assert.NoError(t, serviceInformer.Informer().GetIndexer().Add(service))
+	}
+
+	for _, key := range []string{"default/cluster", "default/pending", "default/nodeport", "default/deleted"} {
+		assert.NoError(t, controller.handleEndpointsChanged(key), key)
+	}
+	assert.NotNil(t, lb.endpointSlices)
+}
@@ -0,0 +1,59 @@
+package linode
+
+import (
+	"errors"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/linode/linode-cloud-controller-manager/cloud/annotations"
+	"github.com/linode/linode-cloud-controller-manager/cloud/linode/firewall"
+)
+
+// Reasons of the events recorded on LoadBalancer Services, so that app teams
+// without access to the CCM logs can see how their NodeBalancer is reconciled,
+// e.g. with kubectl get events --field-selector reason=InvalidAnnotation.
+const (
+	// Normal events
+	eventReasonNodeBalancerCreated       = "NodeBalancerCreated"
+	eventReasonNodeBalancerConfigRebuilt = "NodeBalancerConfigRebuilt"
+	eventReasonNodeBalancerPreserved     = "NodeBalancerPreserved"
+	eventReasonLoadBalancerMigrating     = "LoadBalancerMigrating"
+	eventReasonLoadBalancerMigrated      = "LoadBalancerMigrated"
+	eventReasonFirewallAttached          = firewall.EventReasonFirewallAttached
+
+	// Warning events
+	eventReasonInvalidAnnotation      = "InvalidAnnotation"
+	eventReasonFirewallFailed         = "FirewallUpdateFailed"
+	eventReasonFirewallACLMissing     = "FirewallACLMissing"
+	eventReasonTLSSecretMissing       = "TLSSecretMissing"
+	eventReasonInvalidTLSSecret       = "InvalidTLSSecret"
+	eventReasonTLSCertificateExpiring = "TLSCertificateExpiring"
+	eventReasonNoNodesAvailable       = "NoNodesAvailable"
+	eventReasonLoadBalancerIPFailed   = "LoadBalancerIPUnavailable"
+)
+
+// eventf records an event on service once the CCM is initialized.
+func (l *loadbalancers) eventf(service *v1.Service, eventType, reason, messageFmt string, args ...any) {
+	if l.recorder != nil {
+		l.recorder.Eventf(service, eventType, reason, messageFmt, args...)
+	}
+}
+
+// invalidAnnotation records err, caused by an invalid annotation on service,
+// and returns it.
+func (l *loadbalancers) invalidAnnotation(service *v1.Service, err error) error {
+	countInvalidAnnotation(err)
+	l.eventf(service, v1.EventTypeWarning, eventReasonInvalidAnnotation, "%s", err)
+	return err
+}
+
+// countInvalidAnnotation counts err in the invalid annotations metric, by the
+// annotation that could not be parsed when err is an annotations.ParseError.
+func countInvalidAnnotation(err error) {
+	annotation := "unknown"
+	var parseErr *annotations.ParseError
+	if errors.As(err, &parseErr) {
+		annotation = parseErr.Annotation
+	}
+	invalidAnnotations.WithLabelValues(annotation).Inc()
+}
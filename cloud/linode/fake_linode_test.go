@@ -14,6 +14,7 @@ import (
 	"testing"
 
 	"github.com/linode/linodego"
+	"golang.org/x/exp/slices"
 )
 
 const apiVersion = "v4"
@@ -101,7 +102,9 @@ func (f *fakeAPI) setupRoutes() {
 			}
 			for _, n := range f.nb {
 				if (n.Label != nil && fs["label"] != "" && *n.Label == fs["label"]) ||
-					(fs["ipv4"] != "" && n.IPv4 != nil && *n.IPv4 == fs["ipv4"]) {
+					(fs["ipv4"] != "" && n.IPv4 != nil && *n.IPv4 == fs["ipv4"]) ||
+					(fs["ipv6"] != "" && n.IPv6 != nil && *n.IPv6 == fs["ipv6"]) ||
+					(fs["tags"] != "" && slices.Contains(n.Tags, fs["tags"])) {
 					data = append(data, *n)
 				}
 			}
@@ -256,12 +259,14 @@ func (f *fakeAPI) setupRoutes() {
 		}
 
 		ip := net.IPv4(byte(rand.Intn(100)), byte(rand.Intn(100)), byte(rand.Intn(100)), byte(rand.Intn(100))).String()
+		ipv6 := fmt.Sprintf("2600:3c00::%x", rand.Intn(0xffff))
 		hostname := fmt.Sprintf("nb-%s.%s.linode.com", strings.Replace(ip, ".", "-", 4), strings.ToLower(nbco.Region))
 		nb := linodego.NodeBalancer{
 			ID:       rand.Intn(9999),
 			Label:    nbco.Label,
 			Region:   nbco.Region,
 			IPv4:     &ip,
+			IPv6:     &ipv6,
 			Hostname: &hostname,
 			Tags:     nbco.Tags,
 		}
@@ -636,6 +641,42 @@ func (f *fakeAPI) setupRoutes() {
 		_, _ = w.Write(rr)
 	})
 
+	f.mux.HandleFunc("PUT /v4/networking/firewalls/{firewallID}", func(w http.ResponseWriter, r *http.Request) {
+		fwuo := new(linodego.FirewallUpdateOptions)
+		if err := json.NewDecoder(r.Body).Decode(fwuo); err != nil {
+			f.t.Fatal(err)
+		}
+
+		fwID, err := strconv.Atoi(r.PathValue("firewallID"))
+		if err != nil {
+			f.t.Fatal(err)
+		}
+
+		if firewall, found := f.fw[fwID]; found {
+			if fwuo.Label != "" {
+				firewall.Label = fwuo.Label
+			}
+			if fwuo.Tags != nil {
+				firewall.Tags = *fwuo.Tags
+			}
+			resp, err := json.Marshal(firewall)
+			if err != nil {
+				f.t.Fatal(err)
+			}
+			_, _ = w.Write(resp)
+			return
+		}
+
+		w.WriteHeader(404)
+		resp := linodego.APIError{
+			Errors: []linodego.APIErrorReason{
+				{Reason: "Not Found"},
+			},
+		}
+		rr, _ := json.Marshal(resp)
+		_, _ = w.Write(rr)
+	})
+
 	f.mux.HandleFunc("PUT /v4/nodebalancers/{nodeBalancerId}", func(w http.ResponseWriter, r *http.Request) {
 		nbuo := new(linodego.NodeBalancerUpdateOptions)
 		if err := json.NewDecoder(r.Body).Decode(nbuo); err != nil {
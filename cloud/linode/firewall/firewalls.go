@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/netip"
 	"strconv"
 	"strings"
 
@@ -12,6 +13,7 @@ import (
 
 	"github.com/linode/linodego"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
 
 	"github.com/linode/linode-cloud-controller-manager/cloud/annotations"
@@ -23,21 +25,67 @@ const (
 	maxFirewallRuleDescLen  = 100
 	maxIPsPerFirewall       = 255
 	maxRulesPerFirewall     = 25
+
+	// EventReasonFirewallAttached is the reason of the event recorded on a
+	// Service when a firewall is attached to its NodeBalancer.
+	EventReasonFirewallAttached = "FirewallAttached"
 )
 
 var (
 	ErrTooManyIPs         = errors.New("too many IPs in this ACL, will exceed rules per firewall limit")
 	ErrTooManyNBFirewalls = errors.New("too many firewalls attached to a nodebalancer")
-	ErrInvalidFWConfig    = errors.New("specify either an allowList or a denyList for a firewall")
+	ErrInvalidFWConfig    = errors.New("specify an allowList, a denyList or both, or rules for a firewall")
 )
 
 type LinodeClient struct {
 	Client client.Client
+	// Recorder, if set, records firewall changes on the Service
+	Recorder record.EventRecorder
+	// Feeds are the IP feeds ACLs can reference
+	Feeds *IPFeeds
 }
 
 type aclConfig struct {
-	AllowList *linodego.NetworkAddresses `json:"allowList"`
-	DenyList  *linodego.NetworkAddresses `json:"denyList"`
+	AllowList *aclAddresses `json:"allowList"`
+	DenyList  *aclAddresses `json:"denyList"`
+	// Rules are evaluated in order, each covering its own ports and
+	// protocol, instead of a single list covering every Service port.
+	Rules []aclRule `json:"rules"`
+}
+
+// aclAddresses are the addresses of a list or rule of the ACL annotation.
+type aclAddresses struct {
+	linodego.NetworkAddresses
+	// Feeds are the names of IP feeds whose addresses are added to the
+	// literal ones
+	Feeds []string `json:"feeds,omitempty"`
+}
+
+// aclRule is a firewall rule of the ACL annotation.
+type aclRule struct {
+	// Action is ACCEPT or DROP
+	Action string `json:"action"`
+	// Protocol is TCP or UDP, or both when empty
+	Protocol linodego.NetworkProtocol `json:"protocol"`
+	// Ports defaults to the Service ports of the protocol
+	Ports     string       `json:"ports"`
+	Addresses aclAddresses `json:"addresses"`
+	// Label defaults to the action followed by the Service name
+	Label string `json:"label"`
+}
+
+// attachFirewall attaches the firewall to the NodeBalancer of service.
+func (l *LinodeClient) attachFirewall(ctx context.Context, service *v1.Service, firewallID, nbID int) error {
+	if _, err := l.Client.CreateFirewallDevice(ctx, firewallID, linodego.FirewallDeviceCreateOptions{
+		ID:   nbID,
+		Type: "nodebalancer",
+	}); err != nil {
+		return err
+	}
+	if l.Recorder != nil {
+		l.Recorder.Eventf(service, v1.EventTypeNormal, EventReasonFirewallAttached, "attached firewall %d to NodeBalancer %d", firewallID, nbID)
+	}
+	return nil
 }
 
 func (l *LinodeClient) CreateFirewall(ctx context.Context, opts linodego.FirewallCreateOptions) (fw *linodego.Firewall, err error) {
@@ -63,7 +111,8 @@ func (l *LinodeClient) DeleteNodeBalancerFirewall(
 	nb *linodego.NodeBalancer,
 ) error {
 	_, fwACLExists := service.GetAnnotations()[annotations.AnnLinodeCloudFirewallACL]
-	if fwACLExists { // if an ACL exists, check if firewall exists and delete it.
+	_, fwACLConfigMapExists := service.GetAnnotations()[annotations.AnnLinodeCloudFirewallACLConfigMap]
+	if fwACLExists || fwACLConfigMapExists { // if an ACL exists, check if firewall exists and delete it.
 		firewalls, err := l.Client.ListNodeBalancerFirewalls(ctx, nb.ID, &linodego.ListOptions{})
 		if err != nil {
 			return err
@@ -75,24 +124,38 @@ func (l *LinodeClient) DeleteNodeBalancerFirewall(
 		case 1:
 			return l.DeleteFirewall(ctx, &firewalls[0])
 		default:
-			klog.Errorf("Found more than one firewall attached to nodebalancer: %d, firewall IDs: %v", nb.ID, firewalls)
-			return ErrTooManyNBFirewalls
+			return tooManyFirewallsError(nb.ID, firewalls)
 		}
 	}
 
 	return nil
 }
 
+// tooManyFirewallsError returns ErrTooManyNBFirewalls with the firewalls
+// attached to the NodeBalancer nbID. The ACL of a Service is aggregated to fit
+// in the single firewall managed by the CCM, so more firewalls are attached
+// out of band and must be detached.
+func tooManyFirewallsError(nbID int, firewalls []linodego.Firewall) error {
+	ids := make([]int, 0, len(firewalls))
+	for _, fw := range firewalls {
+		ids = append(ids, fw.ID)
+	}
+	klog.Errorf("Found more than one firewall attached to nodebalancer: %d, firewall IDs: %v", nbID, ids)
+	return fmt.Errorf("%w: %d firewalls %v are attached to NodeBalancer %d, detach all but one", ErrTooManyNBFirewalls, len(firewalls), ids, nbID)
+}
+
 func ipsChanged(ips *linodego.NetworkAddresses, rules []linodego.FirewallRule) bool {
 	var ruleIPv4s []string
 	var ruleIPv6s []string
 
+	// the same addresses are repeated in the rules of every protocol, so
+	// compare unique addresses only.
 	for _, rule := range rules {
 		if rule.Addresses.IPv4 != nil {
-			ruleIPv4s = append(ruleIPv4s, *rule.Addresses.IPv4...)
+			ruleIPv4s = appendUnique(ruleIPv4s, *rule.Addresses.IPv4...)
 		}
 		if rule.Addresses.IPv6 != nil {
-			ruleIPv6s = append(ruleIPv6s, *rule.Addresses.IPv6...)
+			ruleIPv6s = appendUnique(ruleIPv6s, *rule.Addresses.IPv6...)
 		}
 	}
 
@@ -105,10 +168,11 @@ func ipsChanged(ips *linodego.NetworkAddresses, rules []linodego.FirewallRule) b
 	}
 
 	if ips.IPv4 != nil {
-		if len(*ips.IPv4) != len(ruleIPv4s) {
+		ipv4s := appendUnique(nil, *ips.IPv4...)
+		if len(ipv4s) != len(ruleIPv4s) {
 			return true
 		}
-		for _, ipv4 := range *ips.IPv4 {
+		for _, ipv4 := range ipv4s {
 			if !slices.Contains(ruleIPv4s, ipv4) {
 				return true
 			}
@@ -116,10 +180,11 @@ func ipsChanged(ips *linodego.NetworkAddresses, rules []linodego.FirewallRule) b
 	}
 
 	if ips.IPv6 != nil {
-		if len(*ips.IPv6) != len(ruleIPv6s) {
+		ipv6s := appendUnique(nil, *ips.IPv6...)
+		if len(ipv6s) != len(ruleIPv6s) {
 			return true
 		}
-		for _, ipv6 := range *ips.IPv6 {
+		for _, ipv6 := range ipv6s {
 			if !slices.Contains(ruleIPv6s, ipv6) {
 				return true
 			}
@@ -129,39 +194,238 @@ func ipsChanged(ips *linodego.NetworkAddresses, rules []linodego.FirewallRule) b
 	return false
 }
 
-// ruleChanged takes an old FirewallRuleSet and new aclConfig and returns if
-// the IPs of the FirewallRuleSet would be changed with the new ACL Config
-func ruleChanged(old linodego.FirewallRuleSet, newACL aclConfig) bool {
-	var ips *linodego.NetworkAddresses
-	if newACL.AllowList != nil {
-		// this is a allowList, this means that the rules should have `DROP` as inboundpolicy
-		if old.InboundPolicy != "DROP" {
-			return true
+func appendUnique(dst []string, values ...string) []string {
+	for _, v := range values {
+		if !slices.Contains(dst, v) {
+			dst = append(dst, v)
 		}
-		if (newACL.AllowList.IPv4 != nil || newACL.AllowList.IPv6 != nil) && len(old.Inbound) == 0 {
+	}
+	return dst
+}
+
+// portsChanged reports whether the protocol and port pairs covered by the
+// old rules differ from the new ones.
+func portsChanged(old, rules []linodego.FirewallRule) bool {
+	var oldPorts, newPorts []string
+	for _, rule := range old {
+		oldPorts = appendUnique(oldPorts, fmt.Sprintf("%s/%s", rule.Protocol, rule.Ports))
+	}
+	for _, rule := range rules {
+		newPorts = appendUnique(newPorts, fmt.Sprintf("%s/%s", rule.Protocol, rule.Ports))
+	}
+	if len(oldPorts) != len(newPorts) {
+		return true
+	}
+	for _, p := range newPorts {
+		if !slices.Contains(oldPorts, p) {
 			return true
 		}
-		ips = newACL.AllowList
 	}
+	return false
+}
 
-	if newACL.DenyList != nil {
-		if old.InboundPolicy != "ACCEPT" {
-			return true
+// ruleChanged takes an old FirewallRuleSet, a new aclConfig and the rules
+// generated from it, and returns if the FirewallRuleSet would be changed with
+// the new ACL Config. The ports of allowList and denyList rules are compared
+// by portsChanged.
+func ruleChanged(old linodego.FirewallRuleSet, newACL aclConfig, rules linodego.FirewallRuleSet) bool {
+	if newACL.Rules != nil {
+		return old.InboundPolicy != rules.InboundPolicy || rulesChanged(old.Inbound, rules.Inbound)
+	}
+
+	// an allowList drops everything else, a denyList alone accepts it
+	if old.InboundPolicy != rules.InboundPolicy {
+		return true
+	}
+
+	// deny rules are evaluated first, then allow rules
+	var oldDenied, oldAllowed []linodego.FirewallRule
+	for _, rule := range old.Inbound {
+		if rule.Action == "DROP" {
+			if len(oldAllowed) > 0 {
+				return true
+			}
+			oldDenied = append(oldDenied, rule)
+		} else {
+			oldAllowed = append(oldAllowed, rule)
+		}
+	}
+	var denied, allowed []linodego.FirewallRule
+	for _, rule := range rules.Inbound {
+		if rule.Action == "DROP" {
+			denied = append(denied, rule)
+		} else {
+			allowed = append(allowed, rule)
+		}
+	}
+	if (len(oldDenied) == 0) != (len(denied) == 0) || (len(oldAllowed) == 0) != (len(allowed) == 0) {
+		return true
+	}
+
+	// the rules are compared rather than the lists, which may have been
+	// aggregated to fit the firewall
+	deniedIPs, allowedIPs := ruleAddresses(denied), ruleAddresses(allowed)
+	return ipsChanged(&deniedIPs, oldDenied) || ipsChanged(&allowedIPs, oldAllowed)
+}
+
+// ruleAddresses returns the unique addresses of rules.
+func ruleAddresses(rules []linodego.FirewallRule) linodego.NetworkAddresses {
+	var ips linodego.NetworkAddresses
+	for _, rule := range rules {
+		if rule.Addresses.IPv4 != nil {
+			ipv4s := appendUnique(nil, *rule.Addresses.IPv4...)
+			if ips.IPv4 != nil {
+				ipv4s = appendUnique(*ips.IPv4, ipv4s...)
+			}
+			ips.IPv4 = &ipv4s
+		}
+		if rule.Addresses.IPv6 != nil {
+			ipv6s := appendUnique(nil, *rule.Addresses.IPv6...)
+			if ips.IPv6 != nil {
+				ipv6s = appendUnique(*ips.IPv6, ipv6s...)
+			}
+			ips.IPv6 = &ipv6s
 		}
+	}
+	return ips
+}
 
-		if (newACL.DenyList.IPv4 != nil || newACL.DenyList.IPv6 != nil) && len(old.Inbound) == 0 {
+// rulesChanged reports whether the old rules differ from the new ones, which
+// are evaluated in order.
+func rulesChanged(old, rules []linodego.FirewallRule) bool {
+	if len(old) != len(rules) {
+		return true
+	}
+	for i, rule := range rules {
+		if old[i].Action != rule.Action || old[i].Protocol != rule.Protocol || old[i].Ports != rule.Ports || old[i].Label != rule.Label {
+			return true
+		}
+		if ipsChanged(&rule.Addresses, old[i:i+1]) {
 			return true
 		}
-		ips = newACL.DenyList
+	}
+	return false
+}
+
+// aggregateACL returns a copy of acl whose addresses are aggregated into
+// covering CIDRs.
+func aggregateACL(acl aclConfig) aclConfig {
+	aggregated := aclConfig{}
+	if acl.AllowList != nil {
+		aggregated.AllowList = &aclAddresses{NetworkAddresses: aggregateAddresses(acl.AllowList.NetworkAddresses)}
+	}
+	if acl.DenyList != nil {
+		aggregated.DenyList = &aclAddresses{NetworkAddresses: aggregateAddresses(acl.DenyList.NetworkAddresses)}
+	}
+	for _, rule := range acl.Rules {
+		rule.Addresses = aclAddresses{NetworkAddresses: aggregateAddresses(rule.Addresses.NetworkAddresses)}
+		aggregated.Rules = append(aggregated.Rules, rule)
+	}
+	return aggregated
+}
+
+func aggregateAddresses(ips linodego.NetworkAddresses) linodego.NetworkAddresses {
+	var aggregated linodego.NetworkAddresses
+	if ips.IPv4 != nil {
+		ipv4s := aggregateCIDRs(*ips.IPv4)
+		aggregated.IPv4 = &ipv4s
+	}
+	if ips.IPv6 != nil {
+		ipv6s := aggregateCIDRs(*ips.IPv6)
+		aggregated.IPv6 = &ipv6s
+	}
+	return aggregated
+}
+
+// aggregateCIDRs collapses the addresses and CIDRs of ips into the smallest
+// list of CIDRs covering exactly the same addresses, e.g. 10.0.0.0/25 and
+// 10.0.0.128/25 into 10.0.0.0/24. Entries that do not parse are kept as is,
+// for the API to report them.
+func aggregateCIDRs(ips []string) []string {
+	var prefixes []netip.Prefix
+	var invalid []string
+	for _, ip := range ips {
+		prefix, err := netip.ParsePrefix(ip)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(ip)
+			if addrErr != nil {
+				invalid = appendUnique(invalid, ip)
+				continue
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	// sorting by address, then by size, puts every prefix after the prefixes
+	// covering it, and sibling prefixes next to each other
+	slices.SortFunc(prefixes, func(a, b netip.Prefix) int {
+		if c := a.Addr().Compare(b.Addr()); c != 0 {
+			return c
+		}
+		return a.Bits() - b.Bits()
+	})
+
+	var aggregated []netip.Prefix
+	for _, prefix := range prefixes {
+		if len(aggregated) > 0 && aggregated[len(aggregated)-1].Overlaps(prefix) {
+			// covered by the last prefix
+			continue
+		}
+		aggregated = append(aggregated, prefix)
+		// merge the last two prefixes while they are the halves of a
+		// larger one
+		for len(aggregated) > 1 {
+			first, second := aggregated[len(aggregated)-2], aggregated[len(aggregated)-1]
+			if first.Bits() != second.Bits() || first.Bits() == 0 {
+				break
+			}
+			parent := netip.PrefixFrom(first.Addr(), first.Bits()-1).Masked()
+			if parent.Addr() != first.Addr() || !parent.Contains(second.Addr()) {
+				break
+			}
+			aggregated = append(aggregated[:len(aggregated)-2], parent)
+		}
 	}
 
-	return ipsChanged(ips, old.Inbound)
+	cidrs := make([]string, 0, len(aggregated)+len(invalid))
+	for _, prefix := range aggregated {
+		cidrs = append(cidrs, prefix.String())
+	}
+	return append(cidrs, invalid...)
+}
+
+// countAddresses returns the number of addresses and CIDRs of acl.
+func countAddresses(acl aclConfig) int {
+	lists := []*aclAddresses{acl.AllowList, acl.DenyList}
+	for i := range acl.Rules {
+		lists = append(lists, &acl.Rules[i].Addresses)
+	}
+	count := 0
+	for _, ips := range lists {
+		if ips == nil {
+			continue
+		}
+		if ips.IPv4 != nil {
+			count += len(*ips.IPv4)
+		}
+		if ips.IPv6 != nil {
+			count += len(*ips.IPv6)
+		}
+	}
+	return count
 }
 
 func chunkIPs(ips []string) [][]string {
 	chunks := [][]string{}
 	ipCount := len(ips)
 
+	// An allowList or denyList may only hold addresses of one family, which
+	// must not produce a rule without addresses for the other.
+	if ipCount == 0 {
+		return chunks
+	}
+
 	// If the number of IPs is less than or equal to maxIPsPerFirewall,
 	// return a single chunk containing all IPs.
 	if ipCount <= maxIPsPerFirewall {
@@ -195,8 +459,22 @@ func truncateFWRuleDesc(desc string) string {
 }
 
 // processACL takes the IPs, aclType, label etc and formats them into the passed linodego.FirewallCreateOptions pointer.
-func processACL(fwcreateOpts *linodego.FirewallCreateOptions, aclType, label, svcName, ports string, ips linodego.NetworkAddresses) error {
-	ruleLabel := fmt.Sprintf("%s-%s", aclType, svcName)
+func processACL(fwcreateOpts *linodego.FirewallCreateOptions, aclType, label, svcName, ports string, protocol linodego.NetworkProtocol, ips linodego.NetworkAddresses) {
+	appendRules(fwcreateOpts, aclType, fmt.Sprintf("%s-%s", aclType, svcName), label, svcName, ports, protocol, ips)
+
+	fwcreateOpts.Rules.OutboundPolicy = "ACCEPT"
+	if aclType == "ACCEPT" {
+		// if an allowlist is present, we drop everything else.
+		fwcreateOpts.Rules.InboundPolicy = "DROP"
+	} else {
+		// if a denylist is present, we accept everything else.
+		fwcreateOpts.Rules.InboundPolicy = "ACCEPT"
+	}
+}
+
+// appendRules appends the inbound rules with action covering ips on the
+// ports of protocol to fwcreateOpts, split in chunks of maxIPsPerFirewall.
+func appendRules(fwcreateOpts *linodego.FirewallCreateOptions, action, ruleLabel, label, svcName, ports string, protocol linodego.NetworkProtocol, ips linodego.NetworkAddresses) {
 	if len(ruleLabel) > maxFirewallRuleLabelLen {
 		newLabel := ruleLabel[0:maxFirewallRuleLabelLen]
 		klog.Infof("Firewall label '%s' is too long. Stripping to '%s'", ruleLabel, newLabel)
@@ -218,10 +496,10 @@ func processACL(fwcreateOpts *linodego.FirewallCreateOptions, aclType, label, sv
 			v4chunk := chunk
 			desc := fmt.Sprintf("Rule %d, Created by linode-ccm: %s, for %s", i, label, svcName)
 			fwcreateOpts.Rules.Inbound = append(fwcreateOpts.Rules.Inbound, linodego.FirewallRule{
-				Action:      aclType,
+				Action:      action,
 				Label:       ruleLabel,
 				Description: truncateFWRuleDesc(desc),
-				Protocol:    linodego.TCP, // Nodebalancers support only TCP.
+				Protocol:    protocol,
 				Ports:       ports,
 				Addresses:   linodego.NetworkAddresses{IPv4: &v4chunk},
 			})
@@ -232,10 +510,10 @@ func processACL(fwcreateOpts *linodego.FirewallCreateOptions, aclType, label, sv
 			v6chunk := chunk
 			desc := fmt.Sprintf("Rule %d, Created by linode-ccm: %s, for %s", i, label, svcName)
 			fwcreateOpts.Rules.Inbound = append(fwcreateOpts.Rules.Inbound, linodego.FirewallRule{
-				Action:      aclType,
+				Action:      action,
 				Label:       ruleLabel,
 				Description: truncateFWRuleDesc(desc),
-				Protocol:    linodego.TCP, // Nodebalancers support only TCP.
+				Protocol:    protocol,
 				Ports:       ports,
 				Addresses:   linodego.NetworkAddresses{IPv6: &v6chunk},
 			})
@@ -243,27 +521,65 @@ func processACL(fwcreateOpts *linodego.FirewallCreateOptions, aclType, label, sv
 	} else {
 		desc := fmt.Sprintf("Created by linode-ccm: %s, for %s", label, svcName)
 		fwcreateOpts.Rules.Inbound = append(fwcreateOpts.Rules.Inbound, linodego.FirewallRule{
-			Action:      aclType,
+			Action:      action,
 			Label:       ruleLabel,
 			Description: truncateFWRuleDesc(desc),
-			Protocol:    linodego.TCP, // Nodebalancers support only TCP.
+			Protocol:    protocol,
 			Ports:       ports,
 			Addresses:   ips,
 		})
 	}
+}
 
-	fwcreateOpts.Rules.OutboundPolicy = "ACCEPT"
-	if aclType == "ACCEPT" {
-		// if an allowlist is present, we drop everything else.
-		fwcreateOpts.Rules.InboundPolicy = "DROP"
-	} else {
-		// if a denylist is present, we accept everything else.
-		fwcreateOpts.Rules.InboundPolicy = "ACCEPT"
-	}
+// processACLRules formats the rules of the ACL annotation into the passed
+// linodego.FirewallCreateOptions pointer. Rules without ports cover the
+// Service ports of their protocol, given by servicePorts, and rules without
+// protocol cover each protocol of the Service. Traffic matching no rule is
+// dropped if any rule accepts traffic, and accepted otherwise.
+func processACLRules(fwcreateOpts *linodego.FirewallCreateOptions, rules []aclRule, label, svcName string, servicePorts map[linodego.NetworkProtocol]string) error {
+	fwcreateOpts.Rules.InboundPolicy = "ACCEPT"
+	for i, rule := range rules {
+		action := strings.ToUpper(rule.Action)
+		if action != "ACCEPT" && action != "DROP" {
+			return fmt.Errorf("%w: rule %d: action must be ACCEPT or DROP, got %q", ErrInvalidFWConfig, i, rule.Action)
+		}
+		if action == "ACCEPT" {
+			fwcreateOpts.Rules.InboundPolicy = "DROP"
+		}
+		if (rule.Addresses.IPv4 == nil || len(*rule.Addresses.IPv4) == 0) && (rule.Addresses.IPv6 == nil || len(*rule.Addresses.IPv6) == 0) {
+			return fmt.Errorf("%w: rule %d: addresses must hold at least one ipv4 or ipv6 address", ErrInvalidFWConfig, i)
+		}
+
+		protocols := []linodego.NetworkProtocol{linodego.NetworkProtocol(strings.ToUpper(string(rule.Protocol)))}
+		switch protocols[0] {
+		case linodego.TCP, linodego.UDP:
+		case "":
+			protocols = nil
+			for _, protocol := range []linodego.NetworkProtocol{linodego.TCP, linodego.UDP} {
+				if servicePorts[protocol] != "" {
+					protocols = append(protocols, protocol)
+				}
+			}
+		default:
+			return fmt.Errorf("%w: rule %d: protocol must be TCP or UDP, got %q", ErrInvalidFWConfig, i, rule.Protocol)
+		}
 
-	if len(fwcreateOpts.Rules.Inbound) > maxRulesPerFirewall {
-		return ErrTooManyIPs
+		ruleLabel := rule.Label
+		if ruleLabel == "" {
+			ruleLabel = fmt.Sprintf("%s-%s", action, svcName)
+		}
+		for _, protocol := range protocols {
+			ports := rule.Ports
+			if ports == "" {
+				ports = servicePorts[protocol]
+			}
+			if ports == "" {
+				return fmt.Errorf("%w: rule %d: the Service has no %s ports, set the ports of the rule", ErrInvalidFWConfig, i, protocol)
+			}
+			appendRules(fwcreateOpts, action, ruleLabel, label, svcName, ports, protocol, rule.Addresses.NetworkAddresses)
+		}
 	}
+	fwcreateOpts.Rules.OutboundPolicy = "ACCEPT"
 	return nil
 }
 
@@ -312,8 +628,7 @@ func (l *LinodeClient) UpdateNodeBalancerFirewall(
 		return nil
 	}
 	if len(firewalls) > 1 {
-		klog.Errorf("Found more than one firewall attached to nodebalancer: %d, firewall IDs: %v", nb.ID, firewalls)
-		return ErrTooManyNBFirewalls
+		return tooManyFirewallsError(nb.ID, firewalls)
 	}
 	deviceID, deviceExists, err := l.getNodeBalancerDeviceID(ctx, firewalls[0].ID, nb.ID)
 	if err != nil {
@@ -375,8 +690,7 @@ func (l *LinodeClient) updateServiceFirewall(ctx context.Context, service *v1.Se
 		return err
 	}
 	if len(firewalls) > 1 {
-		klog.Errorf("Found more than one firewall attached to nodebalancer: %d, firewall IDs: %v", nb.ID, firewalls)
-		return ErrTooManyNBFirewalls
+		return tooManyFirewallsError(nb.ID, firewalls)
 	}
 
 	// get the ID of the firewall that is already attached to the nodeBalancer, if we have one.
@@ -385,19 +699,14 @@ func (l *LinodeClient) updateServiceFirewall(ctx context.Context, service *v1.Se
 		existingFirewallID = firewalls[0].ID
 	}
 
-	fwID := service.GetAnnotations()[annotations.AnnLinodeCloudFirewallID]
-	newFirewallID, err = strconv.Atoi(fwID)
+	newFirewallID, err = annotations.GetInt(service, annotations.AnnLinodeCloudFirewallID, 0)
 	if err != nil {
 		return err
 	}
 	// if existing firewall and new firewall differs, attach the new firewall and remove the old.
 	if existingFirewallID != newFirewallID {
 		// attach new firewall.
-		_, err = l.Client.CreateFirewallDevice(ctx, newFirewallID, linodego.FirewallDeviceCreateOptions{
-			ID:   nb.ID,
-			Type: "nodebalancer",
-		})
-		if err != nil {
+		if err = l.attachFirewall(ctx, service, newFirewallID, nb.ID); err != nil {
 			return err
 		}
 		// remove the existing firewall if it exists
@@ -436,7 +745,7 @@ func (l *LinodeClient) updateNodeBalancerFirewallWithACL(
 	case 0:
 		{
 			// need to create a fw and attach it to our nb
-			fwcreateOpts, err := CreateFirewallOptsForSvc(loadBalancerName, loadBalancerTags, service)
+			fwcreateOpts, err := l.CreateFirewallOpts(ctx, loadBalancerName, loadBalancerTags, service)
 			if err != nil {
 				return err
 			}
@@ -446,10 +755,7 @@ func (l *LinodeClient) updateNodeBalancerFirewallWithACL(
 				return err
 			}
 			// attach new firewall.
-			if _, err = l.Client.CreateFirewallDevice(ctx, fw.ID, linodego.FirewallDeviceCreateOptions{
-				ID:   nb.ID,
-				Type: "nodebalancer",
-			}); err != nil {
+			if err = l.attachFirewall(ctx, service, fw.ID, nb.ID); err != nil {
 				return err
 			}
 		}
@@ -457,62 +763,205 @@ func (l *LinodeClient) updateNodeBalancerFirewallWithACL(
 		{
 			// We do not want to get into the complexity of reconciling differences, might as well just pull what's in the svc annotation now and update the fw.
 			var acl aclConfig
-			err := json.Unmarshal([]byte(service.GetAnnotations()[annotations.AnnLinodeCloudFirewallACL]), &acl)
+			if _, err = annotations.GetJSON(service, annotations.AnnLinodeCloudFirewallACL, &acl); err != nil {
+				return err
+			}
+
+			fwCreateOpts, err := l.CreateFirewallOpts(ctx, firewalls[0].Label, []string{""}, service)
 			if err != nil {
 				return err
 			}
 
-			changed := ruleChanged(firewalls[0].Rules, acl)
+			changed := ruleChanged(firewalls[0].Rules, acl, fwCreateOpts.Rules) || portsChanged(firewalls[0].Rules.Inbound, fwCreateOpts.Rules.Inbound)
 			if !changed {
 				return nil
 			}
-
-			fwCreateOpts, err := CreateFirewallOptsForSvc(firewalls[0].Label, []string{""}, service)
-			if err != nil {
-				return err
-			}
 			if _, err = l.Client.UpdateFirewallRules(ctx, firewalls[0].ID, fwCreateOpts.Rules); err != nil {
 				return err
 			}
 		}
 	default:
-		klog.Errorf("Found more than one firewall attached to nodebalancer: %d, firewall IDs: %v", nb.ID, firewalls)
-		return ErrTooManyNBFirewalls
+		return tooManyFirewallsError(nb.ID, firewalls)
 	}
 	return nil
 }
 
+// CreateFirewallOptsForSvc returns the options of the firewall of svc, built
+// from its ACL annotation, which cannot reference IP feeds.
 func CreateFirewallOptsForSvc(label string, tags []string, svc *v1.Service) (*linodego.FirewallCreateOptions, error) {
-	// Fetch acl from annotation
-	aclString := svc.GetAnnotations()[annotations.AnnLinodeCloudFirewallACL]
-	fwcreateOpts := linodego.FirewallCreateOptions{
-		Label: label,
-		Tags:  tags,
-	}
-	servicePorts := make([]string, 0, len(svc.Spec.Ports))
-	for _, port := range svc.Spec.Ports {
-		servicePorts = append(servicePorts, strconv.Itoa(int(port.Port)))
-	}
+	return createFirewallOptsForSvc(context.Background(), label, tags, svc, nil)
+}
+
+// CreateFirewallOpts returns the options of the firewall of svc, built from
+// its ACL annotation and the IP feeds it references.
+func (l *LinodeClient) CreateFirewallOpts(ctx context.Context, label string, tags []string, svc *v1.Service) (*linodego.FirewallCreateOptions, error) {
+	return createFirewallOptsForSvc(ctx, label, tags, svc, l.Feeds)
+}
 
-	portsString := strings.Join(servicePorts[:], ",")
+// createFirewallOptsForSvc returns the options of the firewall of svc. The
+// addresses are aggregated into covering CIDRs when the rules would not fit in
+// a single firewall otherwise.
+func createFirewallOptsForSvc(ctx context.Context, label string, tags []string, svc *v1.Service, feeds *IPFeeds) (*linodego.FirewallCreateOptions, error) {
 	var acl aclConfig
-	if err := json.Unmarshal([]byte(aclString), &acl); err != nil {
+	if _, err := annotations.GetJSON(svc, annotations.AnnLinodeCloudFirewallACL, &acl); err != nil {
 		return nil, err
 	}
-	// it is a problem if both are set, or if both are not set
-	if (acl.AllowList != nil && acl.DenyList != nil) || (acl.AllowList == nil && acl.DenyList == nil) {
+	// either the lists or the rules must be set
+	hasList := acl.AllowList != nil || acl.DenyList != nil
+	if hasList == (acl.Rules != nil) {
 		return nil, ErrInvalidFWConfig
 	}
+	acl, err := resolveIPFeeds(ctx, acl, feeds)
+	if err != nil {
+		return nil, err
+	}
 
-	aclType := "ACCEPT"
-	allowedIPs := acl.AllowList
-	if acl.DenyList != nil {
-		aclType = "DROP"
-		allowedIPs = acl.DenyList
+	fwcreateOpts, err := createFirewallOpts(label, tags, svc, acl)
+	if err != nil || len(fwcreateOpts.Rules.Inbound) <= maxRulesPerFirewall {
+		return fwcreateOpts, err
 	}
 
-	if err := processACL(&fwcreateOpts, aclType, label, svc.Name, portsString, *allowedIPs); err != nil {
+	aggregated := aggregateACL(acl)
+	aggregatedOpts, err := createFirewallOpts(label, tags, svc, aggregated)
+	if err != nil {
 		return nil, err
 	}
+	if len(aggregatedOpts.Rules.Inbound) > maxRulesPerFirewall {
+		return nil, fmt.Errorf("%w: %d addresses, %d once aggregated into CIDRs, need %d rules of at most %d addresses, over the limit of %d rules",
+			ErrTooManyIPs, countAddresses(acl), countAddresses(aggregated), len(aggregatedOpts.Rules.Inbound), maxIPsPerFirewall, maxRulesPerFirewall)
+	}
+	klog.Infof("Aggregated the %d addresses of the firewall ACL of service %s into %d CIDRs to fit in %d rules",
+		countAddresses(acl), svc.Name, countAddresses(aggregated), len(aggregatedOpts.Rules.Inbound))
+	return aggregatedOpts, nil
+}
+
+// resolveIPFeeds returns a copy of acl whose addresses include those of the
+// IP feeds they reference.
+func resolveIPFeeds(ctx context.Context, acl aclConfig, feeds *IPFeeds) (aclConfig, error) {
+	resolve := func(addresses *aclAddresses) (*aclAddresses, error) {
+		if addresses == nil || len(addresses.Feeds) == 0 {
+			return addresses, nil
+		}
+		var ipv4s, ipv6s []string
+		if addresses.IPv4 != nil {
+			ipv4s = slices.Clone(*addresses.IPv4)
+		}
+		if addresses.IPv6 != nil {
+			ipv6s = slices.Clone(*addresses.IPv6)
+		}
+		for _, name := range addresses.Feeds {
+			cidrs, err := feeds.Addresses(ctx, name)
+			if err != nil {
+				return nil, err
+			}
+			for _, cidr := range cidrs {
+				if prefix, err := netip.ParsePrefix(cidr); err == nil && prefix.Addr().Is6() {
+					ipv6s = appendUnique(ipv6s, cidr)
+				} else {
+					ipv4s = appendUnique(ipv4s, cidr)
+				}
+			}
+		}
+		resolved := &aclAddresses{}
+		if ipv4s != nil {
+			resolved.IPv4 = &ipv4s
+		}
+		if ipv6s != nil {
+			resolved.IPv6 = &ipv6s
+		}
+		return resolved, nil
+	}
+
+	resolved := aclConfig{}
+	var err error
+	if resolved.AllowList, err = resolve(acl.AllowList); err != nil {
+		return acl, err
+	}
+	if resolved.DenyList, err = resolve(acl.DenyList); err != nil {
+		return acl, err
+	}
+	for _, rule := range acl.Rules {
+		addresses, err := resolve(&rule.Addresses)
+		if err != nil {
+			return acl, err
+		}
+		rule.Addresses = *addresses
+		resolved.Rules = append(resolved.Rules, rule)
+	}
+	return resolved, nil
+}
+
+// ReferencesIPFeeds reports whether the ACL, in the format of the ACL
+// annotation, references one of the IP feeds names.
+func ReferencesIPFeeds(acl string, names []string) bool {
+	var config aclConfig
+	if err := json.Unmarshal([]byte(acl), &config); err != nil {
+		return false
+	}
+	lists := []*aclAddresses{config.AllowList, config.DenyList}
+	for i := range config.Rules {
+		lists = append(lists, &config.Rules[i].Addresses)
+	}
+	for _, addresses := range lists {
+		if addresses == nil {
+			continue
+		}
+		for _, feed := range addresses.Feeds {
+			if slices.Contains(names, feed) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// createFirewallOpts formats acl into the options of the firewall of svc.
+func createFirewallOpts(label string, tags []string, svc *v1.Service, acl aclConfig) (*linodego.FirewallCreateOptions, error) {
+	fwcreateOpts := linodego.FirewallCreateOptions{
+		Label: label,
+		Tags:  tags,
+	}
+	// Group the Service ports by protocol, since firewall rules only cover
+	// a single protocol each.
+	var tcpPorts, udpPorts []string
+	for _, port := range svc.Spec.Ports {
+		if port.Protocol == v1.ProtocolUDP {
+			udpPorts = append(udpPorts, strconv.Itoa(int(port.Port)))
+		} else {
+			tcpPorts = append(tcpPorts, strconv.Itoa(int(port.Port)))
+		}
+	}
+
+	if acl.Rules != nil {
+		servicePorts := map[linodego.NetworkProtocol]string{
+			linodego.TCP: strings.Join(tcpPorts, ","),
+			linodego.UDP: strings.Join(udpPorts, ","),
+		}
+		if err := processACLRules(&fwcreateOpts, acl.Rules, label, svc.Name, servicePorts); err != nil {
+			return nil, err
+		}
+		return &fwcreateOpts, nil
+	}
+
+	// The deny rules come first so that they are evaluated before the allow
+	// rules, whose DROP inbound policy then drops everything else.
+	lists := []struct {
+		aclType string
+		ips     *aclAddresses
+	}{
+		{aclType: "DROP", ips: acl.DenyList},
+		{aclType: "ACCEPT", ips: acl.AllowList},
+	}
+	for _, list := range lists {
+		if list.ips == nil {
+			continue
+		}
+		if len(tcpPorts) > 0 || len(udpPorts) == 0 {
+			processACL(&fwcreateOpts, list.aclType, label, svc.Name, strings.Join(tcpPorts, ","), linodego.TCP, list.ips.NetworkAddresses)
+		}
+		if len(udpPorts) > 0 {
+			processACL(&fwcreateOpts, list.aclType, label, svc.Name, strings.Join(udpPorts, ","), linodego.UDP, list.ips.NetworkAddresses)
+		}
+	}
 	return &fwcreateOpts, nil
 }
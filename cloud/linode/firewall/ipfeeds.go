@@ -0,0 +1,195 @@
+package firewall
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/appscode/go/wait"
+	"golang.org/x/exp/slices"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// maxIPFeedSize bounds the body of HTTP feeds, which hold a few thousand
+	// CIDRs at most
+	maxIPFeedSize = 1 << 20
+	ipFeedTimeout = 30 * time.Second
+)
+
+// ErrIPFeedUnavailable is returned when an IP feed cannot be fetched. It is
+// transient, unlike an unknown feed which is an invalid ACL.
+var ErrIPFeedUnavailable = errors.New("IP feed unavailable")
+
+// DefaultIPFeeds are the URLs of the IP feeds available without configuration,
+// by name.
+var DefaultIPFeeds = map[string]string{
+	"cloudflare-ipv4": "https://www.cloudflare.com/ips-v4",
+	"cloudflare-ipv6": "https://www.cloudflare.com/ips-v6",
+}
+
+// IPFeed is a source of addresses and CIDRs referenced by name from firewall
+// ACLs, e.g. the egress ranges published by a CDN.
+type IPFeed interface {
+	// Fetch returns the current addresses and CIDRs of the feed.
+	Fetch(ctx context.Context) ([]string, error)
+}
+
+// HTTPFeed is an IPFeed served over HTTP as one address or CIDR per line.
+// Blank lines and lines starting with # are ignored.
+type HTTPFeed struct {
+	URL    string
+	Client *http.Client
+}
+
+func (h *HTTPFeed) Fetch(ctx context.Context) ([]string, error) {
+	client := h.Client
+	if client == nil {
+		client = &http.Client{Timeout: ipFeedTimeout}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.URL, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", h.URL, resp.Status)
+	}
+
+	var ips []string
+	scanner := bufio.NewScanner(io.LimitReader(resp.Body, maxIPFeedSize))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// a feed replaced by an error page must not replace the addresses
+		if _, err = netip.ParsePrefix(line); err != nil {
+			if _, err = netip.ParseAddr(line); err != nil {
+				return nil, fmt.Errorf("GET %s: %q is not an address or CIDR", h.URL, line)
+			}
+		}
+		ips = append(ips, line)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("GET %s: no addresses", h.URL)
+	}
+	return ips, nil
+}
+
+// IPFeeds holds the addresses of the IP feeds, by name. A feed is fetched
+// when an ACL first references it, and refreshed by Run afterwards.
+type IPFeeds struct {
+	feeds map[string]IPFeed
+
+	mu sync.Mutex
+	// addresses are the aggregated CIDRs of the fetched feeds
+	addresses   map[string][]string
+	subscribers []func(names []string)
+}
+
+func NewIPFeeds(feeds map[string]IPFeed) *IPFeeds {
+	return &IPFeeds{
+		feeds:     feeds,
+		addresses: make(map[string][]string),
+	}
+}
+
+// Addresses returns the CIDRs of the feed name, fetching it on first use.
+func (f *IPFeeds) Addresses(ctx context.Context, name string) ([]string, error) {
+	var feed IPFeed
+	if f != nil {
+		feed = f.feeds[name]
+	}
+	if feed == nil {
+		return nil, fmt.Errorf("%w: unknown IP feed %q", ErrInvalidFWConfig, name)
+	}
+
+	f.mu.Lock()
+	addresses, ok := f.addresses[name]
+	f.mu.Unlock()
+	if ok {
+		return addresses, nil
+	}
+
+	ips, err := feed.Fetch(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrIPFeedUnavailable, name, err)
+	}
+	addresses = aggregateCIDRs(ips)
+	f.mu.Lock()
+	f.addresses[name] = addresses
+	f.mu.Unlock()
+	return addresses, nil
+}
+
+// Subscribe calls fn with the names of the feeds whose addresses changed on
+// each refresh.
+func (f *IPFeeds) Subscribe(fn func(names []string)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.subscribers = append(f.subscribers, fn)
+}
+
+// Refresh fetches the feeds in use again, and returns the names of those
+// whose addresses changed. A feed that cannot be fetched keeps its addresses.
+func (f *IPFeeds) Refresh(ctx context.Context) []string {
+	f.mu.Lock()
+	names := make([]string, 0, len(f.addresses))
+	for name := range f.addresses {
+		names = append(names, name)
+	}
+	f.mu.Unlock()
+	slices.Sort(names)
+
+	var changed []string
+	for _, name := range names {
+		ips, err := f.feeds[name].Fetch(ctx)
+		if err != nil {
+			klog.Errorf("failed to refresh IP feed %s, keeping its previous addresses: %s", name, err)
+			continue
+		}
+		addresses := aggregateCIDRs(ips)
+		f.mu.Lock()
+		if !slices.Equal(f.addresses[name], addresses) {
+			klog.Infof("IP feed %s changed from %d to %d CIDRs", name, len(f.addresses[name]), len(addresses))
+			f.addresses[name] = addresses
+			changed = append(changed, name)
+		}
+		f.mu.Unlock()
+	}
+	return changed
+}
+
+// Run refreshes the feeds in use every interval, and notifies the subscribers
+// of the feeds that changed.
+func (f *IPFeeds) Run(stopCh <-chan struct{}, interval time.Duration) {
+	wait.Until(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		defer cancel()
+		changed := f.Refresh(ctx)
+		if len(changed) == 0 {
+			return
+		}
+		f.mu.Lock()
+		subscribers := slices.Clone(f.subscribers)
+		f.mu.Unlock()
+		for _, fn := range subscribers {
+			fn(changed)
+		}
+	}, interval, stopCh)
+}
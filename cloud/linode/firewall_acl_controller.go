@@ -0,0 +1,227 @@
+package linode
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/appscode/go/wait"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	v1informers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+
+	"github.com/linode/linode-cloud-controller-manager/cloud/annotations"
+	"github.com/linode/linode-cloud-controller-manager/cloud/linode/firewall"
+)
+
+// firewallACLConfigMapKey is the key of the firewall ACL in the ConfigMaps
+// referenced by AnnLinodeCloudFirewallACLConfigMap.
+const firewallACLConfigMapKey = "acl"
+
+// firewallACLController updates the firewall of the NodeBalancer of Services
+// whose firewall ACL ConfigMap changed, so that an ACL shared by many Services
+// is edited in one place, or whose ACL references an IP feed that changed.
+type firewallACLController struct {
+	loadbalancers   *loadbalancers
+	informer        v1informers.ConfigMapInformer
+	serviceInformer v1informers.ServiceInformer
+	ipFeeds         *firewall.IPFeeds
+
+	queue workqueue.TypedDelayingInterface[any]
+}
+
+func newFirewallACLController(
+	loadbalancers *loadbalancers,
+	informer v1informers.ConfigMapInformer,
+	serviceInformer v1informers.ServiceInformer,
+	ipFeeds *firewall.IPFeeds,
+) *firewallACLController {
+	return &firewallACLController{
+		loadbalancers:   loadbalancers,
+		informer:        informer,
+		serviceInformer: serviceInformer,
+		ipFeeds:         ipFeeds,
+		queue:           workqueue.NewTypedDelayingQueueWithConfig[any](workqueue.TypedDelayingQueueConfig[any]{Name: "ccm_firewall_acl"}),
+	}
+}
+
+func (s *firewallACLController) Run(stopCh <-chan struct{}) {
+	if _, err := s.informer.Informer().AddEventHandler(cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, isInInitialList bool) {
+			configMap, ok := obj.(*v1.ConfigMap)
+			if !ok || isInInitialList {
+				return
+			}
+			// a ConfigMap created after its Service failed to build
+			s.enqueueServices(configMap)
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldConfigMap, ok := oldObj.(*v1.ConfigMap)
+			if !ok {
+				return
+			}
+			newConfigMap, ok := newObj.(*v1.ConfigMap)
+			if !ok || reflect.DeepEqual(oldConfigMap.Data[firewallACLConfigMapKey], newConfigMap.Data[firewallACLConfigMapKey]) {
+				return
+			}
+			klog.Infof("FirewallACLController will handle updated configmap (%s/%s)", newConfigMap.Namespace, newConfigMap.Name)
+			s.enqueueServices(newConfigMap)
+		},
+	}); err != nil {
+		klog.Errorf("FirewallACLController didn't successfully register it's Informer %s", err)
+	}
+	if s.ipFeeds != nil {
+		s.ipFeeds.Subscribe(s.enqueueIPFeedServices)
+	}
+
+	go s.informer.Informer().Run(stopCh)
+	if !cache.WaitForCacheSync(stopCh, s.informer.Informer().HasSynced, s.serviceInformer.Informer().HasSynced) {
+		klog.Errorf("FirewallACLController failed to sync its informers")
+		return
+	}
+
+	wait.Until(s.worker, time.Second, stopCh)
+}
+
+// enqueueServices adds the LoadBalancer Services whose firewall ACL is held
+// by configMap.
+func (s *firewallACLController) enqueueServices(configMap *v1.ConfigMap) {
+	services, err := s.serviceInformer.Lister().Services(configMap.Namespace).List(labels.Everything())
+	if err != nil {
+		klog.Errorf("FirewallACLController failed to list services using configmap (%s/%s): %s", configMap.Namespace, configMap.Name, err)
+		return
+	}
+	for _, service := range services {
+		if service.Spec.Type == v1.ServiceTypeLoadBalancer &&
+			annotations.GetString(service, annotations.AnnLinodeCloudFirewallACLConfigMap, "") == configMap.Name {
+			s.queue.Add(getServiceNn(service))
+		}
+	}
+}
+
+// enqueueIPFeedServices adds the LoadBalancer Services whose firewall ACL
+// references one of the IP feeds names. Services whose firewall already
+// matches the feeds are left untouched by the update.
+func (s *firewallACLController) enqueueIPFeedServices(names []string) {
+	services, err := s.serviceInformer.Lister().List(labels.Everything())
+	if err != nil {
+		klog.Errorf("FirewallACLController failed to list services using IP feeds %v: %s", names, err)
+		return
+	}
+	for _, service := range services {
+		if service.Spec.Type != v1.ServiceTypeLoadBalancer {
+			continue
+		}
+		acl := annotations.GetString(service, annotations.AnnLinodeCloudFirewallACL, "")
+		if name := annotations.GetString(service, annotations.AnnLinodeCloudFirewallACLConfigMap, ""); name != "" {
+			configMap, err := s.informer.Lister().ConfigMaps(service.Namespace).Get(name)
+			if err != nil {
+				continue
+			}
+			acl = configMap.Data[firewallACLConfigMapKey]
+		}
+		if firewall.ReferencesIPFeeds(acl, names) {
+			s.queue.Add(getServiceNn(service))
+		}
+	}
+}
+
+// worker runs a worker thread that dequeues Services whose firewall ACL
+// changed and updates the firewall of their NodeBalancer.
+func (s *firewallACLController) worker() {
+	for s.processNext() {
+	}
+}
+
+func (s *firewallACLController) processNext() bool {
+	key, quit := s.queue.Get()
+	if quit {
+		return false
+	}
+	defer s.queue.Done(key)
+
+	name, ok := key.(string)
+	if !ok {
+		klog.Errorf("expected dequeued key to be of type string but got %T", key)
+		return true
+	}
+
+	if err := s.handleACLChanged(name); err != nil {
+		klog.Errorf("failed to update firewall for service (%s) ACL; retrying in 1 minute: %s", name, err)
+		s.queue.AddAfter(name, retryInterval)
+	}
+	return true
+}
+
+func (s *firewallACLController) handleACLChanged(key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+	service, err := s.serviceInformer.Lister().Services(namespace).Get(name)
+	if err != nil {
+		// deleted Services are handled by the service controller
+		return nil
+	}
+	if service.Spec.Type != v1.ServiceTypeLoadBalancer || len(service.Status.LoadBalancer.Ingress) == 0 ||
+		!s.loadbalancers.usesNodeBalancer(service) {
+		return nil
+	}
+
+	ctx := context.Background()
+	nb, err := s.loadbalancers.getNodeBalancerForService(ctx, Options.ClusterName, service)
+	if err != nil {
+		return err
+	}
+
+	klog.Infof("FirewallACLController updating firewall of NodeBalancer (%d) for service (%s)", nb.ID, key)
+	label := s.loadbalancers.GetLoadBalancerName(ctx, Options.ClusterName, service)
+	tags := s.loadbalancers.GetLoadBalancerTags(ctx, Options.ClusterName, service)
+	return s.loadbalancers.updateNodeBalancerFirewall(ctx, label, tags, service, nb)
+}
+
+// withFirewallACL returns service, or a copy of it whose firewall ACL
+// annotation is set to the ACL of the ConfigMap it references, so that the
+// firewall is built the same way from either annotation.
+func (l *loadbalancers) withFirewallACL(ctx context.Context, service *v1.Service) (*v1.Service, error) {
+	name := annotations.GetString(service, annotations.AnnLinodeCloudFirewallACLConfigMap, "")
+	if name == "" {
+		return service, nil
+	}
+	if _, ok := service.GetAnnotations()[annotations.AnnLinodeCloudFirewallACL]; ok {
+		return nil, l.invalidAnnotation(service, &annotations.ParseError{
+			Annotation: annotations.AnnLinodeCloudFirewallACLConfigMap,
+			Value:      name,
+			Reason:     "cannot be set with " + annotations.AnnLinodeCloudFirewallACL,
+		})
+	}
+
+	if err := l.retrieveKubeClient(); err != nil {
+		return nil, err
+	}
+	configMap, err := l.kubeClient.CoreV1().ConfigMaps(service.Namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		err = fmt.Errorf("firewall ACL configmap %s not found", name)
+		l.eventf(service, v1.EventTypeWarning, eventReasonFirewallACLMissing, "%s", err)
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	acl, ok := configMap.Data[firewallACLConfigMapKey]
+	if !ok {
+		err = fmt.Errorf("firewall ACL configmap %s has no %s key", name, firewallACLConfigMapKey)
+		l.eventf(service, v1.EventTypeWarning, eventReasonFirewallACLMissing, "%s", err)
+		return nil, err
+	}
+
+	service = service.DeepCopy()
+	service.Annotations[annotations.AnnLinodeCloudFirewallACL] = acl
+	return service, nil
+}
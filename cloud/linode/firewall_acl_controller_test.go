@@ -0,0 +1,230 @@
+package linode
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/linode/linodego"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/linode/linode-cloud-controller-manager/cloud/annotations"
+	"github.com/linode/linode-cloud-controller-manager/cloud/linode/client/mocks"
+	"github.com/linode/linode-cloud-controller-manager/cloud/linode/firewall"
+)
+
+func Test_firewallACLController_enqueueServices(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mocks.NewMockClient(ctrl)
+
+	factory := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
+	serviceInformer := factory.Core().V1().Services()
+	lb := newLoadbalancers(client, "us-east").(*loadbalancers)
+	controller := newFirewallACLController(lb, factory.Core().V1().ConfigMaps(), serviceInformer, nil)
+
+	aclAnnotations := map[string]string{annotations.AnnLinodeCloudFirewallACLConfigMap: "office"}
+	for _, service := range []*v1.Service{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "office", Namespace: "default", Annotations: aclAnnotations},
+			Spec:       v1.ServiceSpec{Type: v1.ServiceTypeLoadBalancer},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "other-namespace", Namespace: "other", Annotations: aclAnnotations},
+			Spec:       v1.ServiceSpec{Type: v1.ServiceTypeLoadBalancer},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "no-acl", Namespace: "default"},
+			Spec:       v1.ServiceSpec{Type: v1.ServiceTypeLoadBalancer},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "clusterip", Namespace: "default", Annotations: aclAnnotations},
+			Spec:       v1.ServiceSpec{Type: v1.ServiceTypeClusterIP},
+		},
+	} {
+		assert.NoError(t, serviceInformer.Informer().GetIndexer().Add(service))
+	}
+
+	controller.enqueueServices(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "office", Namespace: "default"}})
+	assert.Equal(t, 1, controller.queue.Len())
+	key, _ := controller.queue.Get()
+	assert.Equal(t, "default/office", key)
+	controller.queue.Done(key)
+
+	controller.enqueueServices(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "unused", Namespace: "default"}})
+	assert.Equal(t, 0, controller.queue.Len())
+}
+
+func Test_firewallACLController_handleACLChanged(t *testing.T) {
+	fakeAPI := newFake(t)
+	ts := httptest.NewServer(fakeAPI)
+	defer ts.Close()
+	linodeClient := linodego.NewClient(http.DefaultClient)
+	linodeClient.SetBaseURL(ts.URL)
+
+	kubeClient := fake.NewSimpleClientset()
+	factory := informers.NewSharedInformerFactory(kubeClient, 0)
+	serviceInformer := factory.Core().V1().Services()
+	lb := newLoadbalancers(&linodeClient, "us-west").(*loadbalancers)
+	lb.kubeClient = kubeClient
+	controller := newFirewallACLController(lb, factory.Core().V1().ConfigMaps(), serviceInformer, nil)
+
+	configMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "office", Namespace: "default"},
+		Data:       map[string]string{firewallACLConfigMapKey: `{"allowList": {"ipv4": ["10.0.0.0/8"]}}`},
+	}
+	_, err := kubeClient.CoreV1().ConfigMaps("default").Create(context.TODO(), configMap, metav1.CreateOptions{})
+	assert.NoError(t, err)
+
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "office",
+			Namespace:   "default",
+			UID:         "foobar123",
+			Annotations: map[string]string{annotations.AnnLinodeCloudFirewallACLConfigMap: "office"},
+		},
+		Spec: v1.ServiceSpec{
+			Type:  v1.ServiceTypeLoadBalancer,
+			Ports: []v1.ServicePort{{Name: "http", Protocol: "TCP", Port: 80, NodePort: 30000}},
+		},
+	}
+	_, err = kubeClient.CoreV1().Services(svc.Namespace).Create(context.TODO(), svc, metav1.CreateOptions{})
+	assert.NoError(t, err)
+
+	nodes := []*v1.Node{{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}}
+	status, err := lb.EnsureLoadBalancer(context.TODO(), "linodelb", svc, nodes)
+	assert.NoError(t, err)
+	svc.Status.LoadBalancer = *status
+	assert.NoError(t, serviceInformer.Informer().GetIndexer().Add(svc))
+
+	firewallIPv4s := func() []string {
+		nbs, err := linodeClient.ListNodeBalancers(context.TODO(), nil)
+		assert.NoError(t, err)
+		assert.Len(t, nbs, 1)
+		firewalls, err := linodeClient.ListNodeBalancerFirewalls(context.TODO(), nbs[0].ID, nil)
+		assert.NoError(t, err)
+		assert.Len(t, firewalls, 1)
+		var ipv4s []string
+		for _, rule := range firewalls[0].Rules.Inbound {
+			ipv4s = append(ipv4s, *rule.Addresses.IPv4...)
+		}
+		return ipv4s
+	}
+	assert.Equal(t, []string{"10.0.0.0/8"}, firewallIPv4s(), "expected the firewall to be created from the configmap")
+
+	configMap.Data[firewallACLConfigMapKey] = `{"allowList": {"ipv4": ["192.168.0.0/16"]}}`
+	_, err = kubeClient.CoreV1().ConfigMaps("default").Update(context.TODO(), configMap, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+	assert.NoError(t, controller.handleACLChanged("default/office"))
+	assert.Equal(t, []string{"192.168.0.0/16"}, firewallIPv4s(), "expected the firewall to follow the configmap")
+
+	// a missing configmap must be retried
+	assert.NoError(t, kubeClient.CoreV1().ConfigMaps("default").Delete(context.TODO(), "office", metav1.DeleteOptions{}))
+	assert.Error(t, controller.handleACLChanged("default/office"))
+	assert.NoError(t, controller.handleACLChanged("default/deleted"))
+}
+
+func Test_firewallACLController_ipFeeds(t *testing.T) {
+	fakeAPI := newFake(t)
+	ts := httptest.NewServer(fakeAPI)
+	defer ts.Close()
+	linodeClient := linodego.NewClient(http.DefaultClient)
+	linodeClient.SetBaseURL(ts.URL)
+
+	// a local stand-in for a published IP feed
+	feed := "# office ranges\n10.0.0.0/25\n10.0.0.128/25\n"
+	feedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(feed))
+	}))
+	defer feedServer.Close()
+
+	kubeClient := fake.NewSimpleClientset()
+	factory := informers.NewSharedInformerFactory(kubeClient, 0)
+	serviceInformer := factory.Core().V1().Services()
+	lb := newLoadbalancers(&linodeClient, "us-west").(*loadbalancers)
+	lb.kubeClient = kubeClient
+	lb.ipFeeds = firewall.NewIPFeeds(map[string]firewall.IPFeed{"office": &firewall.HTTPFeed{URL: feedServer.URL}})
+	controller := newFirewallACLController(lb, factory.Core().V1().ConfigMaps(), serviceInformer, lb.ipFeeds)
+
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "office",
+			Namespace:   "default",
+			UID:         "foobar123",
+			Annotations: map[string]string{annotations.AnnLinodeCloudFirewallACL: `{"allowList": {"ipv4": ["192.168.0.1"], "feeds": ["office"]}}`},
+		},
+		Spec: v1.ServiceSpec{
+			Type:  v1.ServiceTypeLoadBalancer,
+			Ports: []v1.ServicePort{{Name: "http", Protocol: "TCP", Port: 80, NodePort: 30000}},
+		},
+	}
+	_, err := kubeClient.CoreV1().Services(svc.Namespace).Create(context.TODO(), svc, metav1.CreateOptions{})
+	assert.NoError(t, err)
+	other := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "other",
+			Namespace:   "default",
+			Annotations: map[string]string{annotations.AnnLinodeCloudFirewallACL: `{"allowList": {"ipv4": ["192.168.0.1"]}}`},
+		},
+		Spec: v1.ServiceSpec{Type: v1.ServiceTypeLoadBalancer},
+	}
+	assert.NoError(t, serviceInformer.Informer().GetIndexer().Add(other))
+
+	nodes := []*v1.Node{{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}}
+	status, err := lb.EnsureLoadBalancer(context.TODO(), "linodelb", svc, nodes)
+	assert.NoError(t, err)
+	svc.Status.LoadBalancer = *status
+	assert.NoError(t, serviceInformer.Informer().GetIndexer().Add(svc))
+
+	firewallIPv4s := func() []string {
+		nbs, err := linodeClient.ListNodeBalancers(context.TODO(), nil)
+		assert.NoError(t, err)
+		assert.Len(t, nbs, 1)
+		firewalls, err := linodeClient.ListNodeBalancerFirewalls(context.TODO(), nbs[0].ID, nil)
+		assert.NoError(t, err)
+		assert.Len(t, firewalls, 1)
+		var ipv4s []string
+		for _, rule := range firewalls[0].Rules.Inbound {
+			ipv4s = append(ipv4s, *rule.Addresses.IPv4...)
+		}
+		return ipv4s
+	}
+	assert.Equal(t, []string{"192.168.0.1", "10.0.0.0/24"}, firewallIPv4s(), "expected the aggregated feed to be added to the allowList")
+
+	// an unchanged feed must not update the firewall
+	assert.Empty(t, lb.ipFeeds.Refresh(context.TODO()))
+
+	// a feed replaced by an error page must keep its addresses
+	feed = "<html>oops</html>"
+	assert.Empty(t, lb.ipFeeds.Refresh(context.TODO()))
+
+	feed = "10.1.0.0/16\n"
+	changed := lb.ipFeeds.Refresh(context.TODO())
+	assert.Equal(t, []string{"office"}, changed)
+	controller.enqueueIPFeedServices(changed)
+	assert.Equal(t, 1, controller.queue.Len(), "expected only the Service using the feed to be enqueued")
+	key, _ := controller.queue.Get()
+	assert.Equal(t, "default/office", key)
+	controller.queue.Done(key)
+
+	assert.NoError(t, controller.handleACLChanged("default/office"))
+	assert.Equal(t, []string{"192.168.0.1", "10.1.0.0/16"}, firewallIPv4s(), "expected the firewall to follow the feed")
+
+	fakeAPI.ResetRequests()
+	assert.NoError(t, controller.handleACLChanged("default/office"))
+	for req := range fakeAPI.requests {
+		assert.NotEqual(t, http.MethodPut, req.Method, "unexpected firewall update: %s", req.Path)
+	}
+
+	// unknown feeds are invalid
+	svc.Annotations[annotations.AnnLinodeCloudFirewallACL] = `{"allowList": {"feeds": ["unknown"]}}`
+	fwClient := firewall.LinodeClient{Feeds: lb.ipFeeds}
+	_, err = fwClient.CreateFirewallOpts(context.TODO(), "test", nil, svc)
+	assert.ErrorIs(t, err, firewall.ErrInvalidFWConfig)
+}
@@ -0,0 +1,200 @@
+package linode
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/appscode/go/wait"
+	"github.com/linode/linodego"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	v1informers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+
+	"github.com/linode/linode-cloud-controller-manager/cloud/annotations"
+	"github.com/linode/linode-cloud-controller-manager/cloud/linode/client"
+)
+
+const (
+	defaultGCInterval    = time.Hour
+	defaultGCGracePeriod = 24 * time.Hour
+	// defaultClusterName is the cluster name of the CCM when --cluster-name
+	// is not set, which clusters sharing an account likely have in common
+	defaultClusterName = "kubernetes"
+)
+
+// garbageCollector periodically removes NodeBalancers and firewalls that were
+// created for this cluster but whose Service no longer exists, e.g. because the
+// Service was deleted while the CCM was down or its deletion was not retried.
+type garbageCollector struct {
+	client      client.Client
+	informer    v1informers.ServiceInformer
+	clusterName string
+	interval    time.Duration
+	gracePeriod time.Duration
+	dryRun      bool
+}
+
+func newGarbageCollector(client client.Client, informer v1informers.ServiceInformer) *garbageCollector {
+	interval := Options.NodeBalancerGCInterval
+	if interval <= 0 {
+		interval = defaultGCInterval
+	}
+	gracePeriod := Options.NodeBalancerGCGracePeriod
+	if gracePeriod < 0 {
+		gracePeriod = defaultGCGracePeriod
+	}
+	return &garbageCollector{
+		client:      client,
+		informer:    informer,
+		clusterName: Options.ClusterName,
+		interval:    interval,
+		gracePeriod: gracePeriod,
+		dryRun:      Options.NodeBalancerGCDryRun,
+	}
+}
+
+func (g *garbageCollector) Run(stopCh <-chan struct{}) {
+	if err := validateGCClusterName(g.clusterName); err != nil {
+		klog.Errorf("GarbageCollector not starting: %s", err)
+		return
+	}
+	if !cache.WaitForCacheSync(stopCh, g.informer.Informer().HasSynced) {
+		klog.Errorf("GarbageCollector failed to sync the Service informer")
+		return
+	}
+
+	wait.Until(func() {
+		if err := g.collect(context.Background()); err != nil {
+			klog.Errorf("GarbageCollector failed to collect leaked resources: %s", err)
+		}
+	}, g.interval, stopCh)
+}
+
+// validateGCClusterName returns an error unless clusterName identifies the
+// cluster, as the garbage collector would otherwise delete the NodeBalancers
+// of other clusters of the account.
+func validateGCClusterName(clusterName string) error {
+	if clusterName == "" || clusterName == defaultClusterName {
+		return fmt.Errorf("the nodebalancer garbage collector requires a unique --cluster-name, not %q", clusterName)
+	}
+	return nil
+}
+
+// collect deletes, or reports in dry-run mode, every NodeBalancer and firewall
+// tagged with the cluster tag whose owning Service is gone.
+func (g *garbageCollector) collect(ctx context.Context) error {
+	owners, nbIDs, err := g.liveOwners()
+	if err != nil {
+		return err
+	}
+
+	filter := fmt.Sprintf(`{"tags": "%v"}`, clusterTag(g.clusterName))
+	nbs, err := g.client.ListNodeBalancers(ctx, &linodego.ListOptions{Filter: filter})
+	if err != nil {
+		return err
+	}
+	for _, nb := range nbs {
+		uid, orphaned := g.isOrphaned(nb.Tags, nb.Created, owners)
+		if !orphaned || nbIDs[nb.ID] {
+			continue
+		}
+		if g.dryRun {
+			klog.Infof("GarbageCollector found leaked NodeBalancer (%d) for deleted service %s, not deleting in dry-run mode", nb.ID, uid)
+			continue
+		}
+		// deleting the NodeBalancer detaches its firewall, which is then
+		// collected below
+		if err = g.client.DeleteNodeBalancer(ctx, nb.ID); err != nil {
+			return err
+		}
+		klog.Infof("GarbageCollector deleted leaked NodeBalancer (%d) for deleted service %s", nb.ID, uid)
+	}
+
+	firewalls, err := g.client.ListFirewalls(ctx, &linodego.ListOptions{Filter: filter})
+	if err != nil {
+		return err
+	}
+	for _, fw := range firewalls {
+		uid, orphaned := g.isOrphaned(fw.Tags, fw.Created, owners)
+		if !orphaned {
+			continue
+		}
+		devices, err := g.client.ListFirewallDevices(ctx, fw.ID, &linodego.ListOptions{})
+		if err != nil {
+			return err
+		}
+		if len(devices) > 0 {
+			continue
+		}
+		if g.dryRun {
+			klog.Infof("GarbageCollector found leaked firewall (%d) for deleted service %s, not deleting in dry-run mode", fw.ID, uid)
+			continue
+		}
+		if err = g.client.DeleteFirewall(ctx, fw.ID); err != nil {
+			return err
+		}
+		klog.Infof("GarbageCollector deleted leaked firewall (%d) for deleted service %s", fw.ID, uid)
+	}
+	return nil
+}
+
+// liveOwners returns the UIDs of the LoadBalancer Services that currently
+// exist, and the IDs of the NodeBalancers they reference by annotation.
+func (g *garbageCollector) liveOwners() (map[string]bool, map[int]bool, error) {
+	services, err := g.informer.Lister().List(labels.Everything())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	owners := make(map[string]bool, len(services))
+	nbIDs := make(map[int]bool)
+	for _, service := range services {
+		if service.Spec.Type != v1.ServiceTypeLoadBalancer {
+			continue
+		}
+		owners[string(service.UID)] = true
+		if id, err := parseNodeBalancerID(service); err == nil {
+			nbIDs[id] = true
+		}
+	}
+	return owners, nbIDs, nil
+}
+
+// isOrphaned reports whether a resource with the given tags belongs to a
+// Service of this cluster that no longer exists. Resources without the cluster
+// tag or an owner tag, marked as preserved, or created within the grace period
+// are never orphaned.
+func (g *garbageCollector) isOrphaned(tags []string, created *time.Time, owners map[string]bool) (string, bool) {
+	var uid string
+	var ownCluster bool
+	for _, tag := range tags {
+		if tag == preserveTag {
+			return "", false
+		}
+		if tag == clusterTag(g.clusterName) {
+			ownCluster = true
+		}
+		if strings.HasPrefix(tag, serviceUIDTagPrefix) {
+			uid = strings.TrimPrefix(tag, serviceUIDTagPrefix)
+		}
+	}
+	if !ownCluster || uid == "" || owners[uid] {
+		return uid, false
+	}
+	if created == nil || time.Since(*created) < g.gracePeriod {
+		return uid, false
+	}
+	return uid, true
+}
+
+// parseNodeBalancerID returns the NodeBalancer ID set by annotation on service.
+func parseNodeBalancerID(service *v1.Service) (int, error) {
+	if _, ok := service.GetAnnotations()[annotations.AnnLinodeNodeBalancerID]; !ok {
+		return 0, fmt.Errorf("service %s has no %s annotation", getServiceNn(service), annotations.AnnLinodeNodeBalancerID)
+	}
+	return annotations.GetInt(service, annotations.AnnLinodeNodeBalancerID, 0)
+}
@@ -0,0 +1,107 @@
+package linode
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/linode/linodego"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/linode/linode-cloud-controller-manager/cloud/annotations"
+	"github.com/linode/linode-cloud-controller-manager/cloud/linode/client/mocks"
+)
+
+func Test_garbageCollector_collect(t *testing.T) {
+	old := time.Now().Add(-48 * time.Hour)
+	recent := time.Now().Add(-time.Hour)
+
+	liveService := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "live",
+			Namespace: "default",
+			UID:       "live-uid",
+		},
+		Spec: v1.ServiceSpec{Type: v1.ServiceTypeLoadBalancer},
+	}
+	annotatedService := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "annotated",
+			Namespace:   "default",
+			UID:         "annotated-uid",
+			Annotations: map[string]string{annotations.AnnLinodeNodeBalancerID: "4"},
+		},
+		Spec: v1.ServiceSpec{Type: v1.ServiceTypeLoadBalancer},
+	}
+
+	nodeBalancers := []linodego.NodeBalancer{
+		{ID: 1, Created: &old, Tags: []string{"cluster", "ccm-cluster:cluster", "ccm-uid:gone-uid"}},
+		{ID: 2, Created: &old, Tags: []string{"cluster", "ccm-cluster:cluster", "ccm-uid:live-uid"}},
+		{ID: 3, Created: &old, Tags: []string{"cluster", "ccm-cluster:cluster", "ccm-uid:preserved-uid", preserveTag}},
+		{ID: 4, Created: &old, Tags: []string{"cluster", "ccm-cluster:cluster", "ccm-uid:adopted-uid"}},
+		{ID: 5, Created: &recent, Tags: []string{"cluster", "ccm-cluster:cluster", "ccm-uid:new-uid"}},
+		{ID: 6, Created: &old, Tags: []string{"cluster", "ccm-cluster:cluster"}},
+		// tagged with the cluster name by a user or a CCM with another name
+		{ID: 7, Created: &old, Tags: []string{"cluster", "ccm-uid:gone-uid"}},
+		{ID: 8, Created: &old, Tags: []string{"ccm-cluster:other", "ccm-uid:gone-uid"}},
+	}
+	firewalls := []linodego.Firewall{
+		{ID: 10, Created: &old, Tags: []string{"cluster", "ccm-cluster:cluster", "ccm-uid:gone-uid"}},
+		{ID: 11, Created: &old, Tags: []string{"cluster", "ccm-cluster:cluster", "ccm-uid:attached-uid"}},
+		{ID: 12, Created: &old, Tags: []string{"cluster", "ccm-cluster:cluster", "ccm-uid:live-uid"}},
+	}
+
+	tests := []struct {
+		name   string
+		dryRun bool
+		expect func(*mocks.MockClient)
+	}{
+		{
+			name: "deletes orphans",
+			expect: func(c *mocks.MockClient) {
+				c.EXPECT().DeleteNodeBalancer(gomock.Any(), 1).Return(nil)
+				c.EXPECT().ListFirewallDevices(gomock.Any(), 10, gomock.Any()).Return(nil, nil)
+				c.EXPECT().DeleteFirewall(gomock.Any(), 10).Return(nil)
+				c.EXPECT().ListFirewallDevices(gomock.Any(), 11, gomock.Any()).Return([]linodego.FirewallDevice{{ID: 7}}, nil)
+			},
+		},
+		{
+			name:   "dry run",
+			dryRun: true,
+			expect: func(c *mocks.MockClient) {
+				c.EXPECT().ListFirewallDevices(gomock.Any(), 10, gomock.Any()).Return(nil, nil)
+				c.EXPECT().ListFirewallDevices(gomock.Any(), 11, gomock.Any()).Return([]linodego.FirewallDevice{{ID: 7}}, nil)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			client := mocks.NewMockClient(ctrl)
+
+			informer := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0).Core().V1().Services()
+			assert.NoError(t, informer.Informer().GetIndexer().Add(liveService))
+			assert.NoError(t, informer.Informer().GetIndexer().Add(annotatedService))
+
+			client.EXPECT().ListNodeBalancers(gomock.Any(), &linodego.ListOptions{Filter: `{"tags": "ccm-cluster:cluster"}`}).Return(nodeBalancers, nil)
+			client.EXPECT().ListFirewalls(gomock.Any(), &linodego.ListOptions{Filter: `{"tags": "ccm-cluster:cluster"}`}).Return(firewalls, nil)
+			tt.expect(client)
+
+			g := &garbageCollector{
+				client:      client,
+				informer:    informer,
+				clusterName: "cluster",
+				interval:    defaultGCInterval,
+				gracePeriod: defaultGCGracePeriod,
+				dryRun:      tt.dryRun,
+			}
+			assert.NoError(t, g.collect(context.TODO()))
+		})
+	}
+}
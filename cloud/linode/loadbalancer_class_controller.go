@@ -0,0 +1,193 @@
+package linode
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/appscode/go/wait"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	v1informers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+)
+
+// nodeUpdateDelay batches the node changes of a rollout into a single update
+// of the load balancers.
+var nodeUpdateDelay = 5 * time.Second
+
+// loadBalancerClassController provisions the load balancers of Services with
+// one of the Linode load balancer classes. The cloud-provider service
+// controller skips every Service with a spec.loadBalancerClass, so they would
+// otherwise never be reconciled. Their deletion is handled by the
+// serviceController, like the deletion of Services without a class.
+type loadBalancerClassController struct {
+	loadbalancers   *loadbalancers
+	serviceInformer v1informers.ServiceInformer
+	nodeInformer    v1informers.NodeInformer
+
+	queue workqueue.TypedDelayingInterface[any]
+}
+
+func newLoadBalancerClassController(
+	loadbalancers *loadbalancers,
+	serviceInformer v1informers.ServiceInformer,
+	nodeInformer v1informers.NodeInformer,
+) *loadBalancerClassController {
+	return &loadBalancerClassController{
+		loadbalancers:   loadbalancers,
+		serviceInformer: serviceInformer,
+		nodeInformer:    nodeInformer,
+		queue:           workqueue.NewTypedDelayingQueueWithConfig[any](workqueue.TypedDelayingQueueConfig[any]{Name: "ccm_loadbalancer_class"}),
+	}
+}
+
+func (s *loadBalancerClassController) Run(stopCh <-chan struct{}) {
+	if _, err := s.serviceInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if service, ok := obj.(*v1.Service); ok && s.handles(service) {
+				s.queue.Add(getServiceNn(service))
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldSvc, ok := oldObj.(*v1.Service)
+			if !ok {
+				return
+			}
+			newSvc, ok := newObj.(*v1.Service)
+			if !ok || !s.handles(newSvc) {
+				return
+			}
+			// skip the status updates of the controller itself
+			if reflect.DeepEqual(oldSvc.Spec, newSvc.Spec) && reflect.DeepEqual(oldSvc.Annotations, newSvc.Annotations) {
+				return
+			}
+			s.queue.Add(getServiceNn(newSvc))
+		},
+	}); err != nil {
+		klog.Errorf("LoadBalancerClassController didn't successfully register it's Service Informer %s", err)
+	}
+
+	enqueueAll := func(interface{}) { s.enqueueServices() }
+	if _, err := s.nodeInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: enqueueAll,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldNode, ok := oldObj.(*v1.Node)
+			if !ok {
+				return
+			}
+			newNode, ok := newObj.(*v1.Node)
+			if !ok {
+				return
+			}
+			if reflect.DeepEqual(oldNode.Labels, newNode.Labels) &&
+				oldNode.Spec.Unschedulable == newNode.Spec.Unschedulable &&
+				reflect.DeepEqual(oldNode.Status.Addresses, newNode.Status.Addresses) {
+				return
+			}
+			s.enqueueServices()
+		},
+		DeleteFunc: enqueueAll,
+	}); err != nil {
+		klog.Errorf("LoadBalancerClassController didn't successfully register it's Node Informer %s", err)
+	}
+
+	if !cache.WaitForCacheSync(stopCh, s.serviceInformer.Informer().HasSynced, s.nodeInformer.Informer().HasSynced) {
+		klog.Errorf("LoadBalancerClassController failed to sync its informers")
+		return
+	}
+
+	wait.Until(s.worker, time.Second, stopCh)
+}
+
+// handles reports whether service is a LoadBalancer Service of a Linode
+// load balancer class. Services without a class are reconciled by the
+// cloud-provider service controller.
+func (s *loadBalancerClassController) handles(service *v1.Service) bool {
+	return service.Spec.Type == v1.ServiceTypeLoadBalancer &&
+		service.Spec.LoadBalancerClass != nil &&
+		s.loadbalancers.claimsService(service)
+}
+
+// enqueueServices adds every Service of a Linode load balancer class, whose
+// backends follow the nodes of the cluster.
+func (s *loadBalancerClassController) enqueueServices() {
+	services, err := s.serviceInformer.Lister().List(labels.Everything())
+	if err != nil {
+		klog.Errorf("LoadBalancerClassController failed to list services: %s", err)
+		return
+	}
+	for _, service := range services {
+		if s.handles(service) {
+			s.queue.AddAfter(getServiceNn(service), nodeUpdateDelay)
+		}
+	}
+}
+
+// worker runs a worker thread that dequeues Services of a Linode load
+// balancer class and ensures their load balancer.
+func (s *loadBalancerClassController) worker() {
+	for s.processNext() {
+	}
+}
+
+func (s *loadBalancerClassController) processNext() bool {
+	key, quit := s.queue.Get()
+	if quit {
+		return false
+	}
+	defer s.queue.Done(key)
+
+	name, ok := key.(string)
+	if !ok {
+		klog.Errorf("expected dequeued key to be of type string but got %T", key)
+		return true
+	}
+
+	if err := s.handleServiceChanged(name); err != nil {
+		klog.Errorf("failed to ensure load balancer for service (%s); retrying in 1 minute: %s", name, err)
+		s.queue.AddAfter(name, retryInterval)
+	}
+	return true
+}
+
+func (s *loadBalancerClassController) handleServiceChanged(key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+	service, err := s.serviceInformer.Lister().Services(namespace).Get(name)
+	if err != nil {
+		// deleted Services are handled by the service controller
+		return nil
+	}
+	if !s.handles(service) || service.DeletionTimestamp != nil {
+		return nil
+	}
+
+	nodes, err := getLoadBalancerNodes(s.nodeInformer.Lister())
+	if err != nil {
+		return err
+	}
+
+	klog.Infof("LoadBalancerClassController ensuring load balancer for service (%s) of class %s", key, *service.Spec.LoadBalancerClass)
+	ctx := context.Background()
+	status, err := s.loadbalancers.EnsureLoadBalancer(ctx, Options.ClusterName, service.DeepCopy(), nodes)
+	if err != nil {
+		return err
+	}
+	if status == nil || reflect.DeepEqual(service.Status.LoadBalancer, *status) {
+		return nil
+	}
+
+	if err = s.loadbalancers.retrieveKubeClient(); err != nil {
+		return err
+	}
+	updated := service.DeepCopy()
+	updated.Status.LoadBalancer = *status
+	_, err = s.loadbalancers.kubeClient.CoreV1().Services(namespace).UpdateStatus(ctx, updated, metav1.UpdateOptions{})
+	return err
+}
@@ -0,0 +1,96 @@
+package linode
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/linode/linodego"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
+
+	"github.com/linode/linode-cloud-controller-manager/cloud/linode/client/mocks"
+)
+
+func newTestClassService(name, class string) *v1.Service {
+	return &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: "uid-" + types.UID(name)},
+		Spec: v1.ServiceSpec{
+			Type:              v1.ServiceTypeLoadBalancer,
+			LoadBalancerClass: ptr.To(class),
+			Ports:             []v1.ServicePort{{Name: "http", Protocol: "TCP", Port: 80, NodePort: 30000}},
+		},
+	}
+}
+
+func Test_loadBalancerClassController_handleServiceChanged(t *testing.T) {
+	fakeAPI := newFake(t)
+	ts := httptest.NewServer(fakeAPI)
+	defer ts.Close()
+	linodeClient := linodego.NewClient(http.DefaultClient)
+	linodeClient.SetBaseURL(ts.URL)
+
+	kubeClient := fake.NewSimpleClientset()
+	factory := informers.NewSharedInformerFactory(kubeClient, 0)
+	serviceInformer := factory.Core().V1().Services()
+	nodeInformer := factory.Core().V1().Nodes()
+
+	lb := newLoadbalancers(&linodeClient, "us-west").(*loadbalancers)
+	lb.kubeClient = kubeClient
+	lb.loadBalancerClasses = map[string]string{"linode.com/nodebalancer": nodeBalancerLBType}
+	controller := newLoadBalancerClassController(lb, serviceInformer, nodeInformer)
+
+	assert.NoError(t, nodeInformer.Informer().GetIndexer().Add(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}))
+	for _, svc := range []*v1.Service{
+		newTestClassService("linode", "linode.com/nodebalancer"),
+		newTestClassService("other", "example.com/other"),
+	} {
+		_, err := kubeClient.CoreV1().Services(svc.Namespace).Create(context.TODO(), svc, metav1.CreateOptions{})
+		assert.NoError(t, err)
+		assert.NoError(t, serviceInformer.Informer().GetIndexer().Add(svc))
+	}
+
+	for _, key := range []string{"default/linode", "default/other", "default/deleted"} {
+		assert.NoError(t, controller.handleServiceChanged(key), key)
+	}
+
+	nbs, err := linodeClient.ListNodeBalancers(context.TODO(), nil)
+	assert.NoError(t, err)
+	assert.Len(t, nbs, 1, "expected a NodeBalancer for the Linode class only")
+
+	linode, err := kubeClient.CoreV1().Services("default").Get(context.TODO(), "linode", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, linode.Status.LoadBalancer.Ingress, "expected the status of the Service to be updated")
+	other, err := kubeClient.CoreV1().Services("default").Get(context.TODO(), "other", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, other.Status.LoadBalancer.Ingress)
+}
+
+func Test_loadbalancers_claimsService(t *testing.T) {
+	lb := &loadbalancers{loadBalancerClasses: map[string]string{"linode.com/cilium-bgp": ciliumLBType}}
+
+	assert.True(t, lb.claimsService(&v1.Service{}), "expected Services without a class to be claimed")
+	assert.True(t, lb.claimsService(newTestClassService("svc", "linode.com/cilium-bgp")))
+	assert.False(t, lb.claimsService(newTestClassService("svc", "example.com/other")))
+
+	lbType, err := lb.getLoadBalancerType(newTestClassService("svc", "linode.com/cilium-bgp"))
+	assert.NoError(t, err)
+	assert.Equal(t, ciliumLBType, lbType)
+}
+
+func TestEnsureLoadBalancerDeletedUnclaimedClass(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	// the load balancer of another implementation must be left alone
+	client := mocks.NewMockClient(ctrl)
+	lb := &loadbalancers{client: client, zone: zone, loadBalancerType: nodeBalancerLBType}
+
+	assert.NoError(t, lb.EnsureLoadBalancerDeleted(context.TODO(), "linodelb", newTestClassService("svc", "example.com/other")))
+}
@@ -0,0 +1,256 @@
+package linode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"golang.org/x/exp/slices"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+
+	"github.com/linode/linode-cloud-controller-manager/cloud/annotations"
+	"github.com/linode/linode-cloud-controller-manager/sentry"
+)
+
+// defaultLoadBalancerType returns the type of the load balancers of Services
+// without a type annotation.
+func (l *loadbalancers) defaultLoadBalancerType() string {
+	if l.loadBalancerType == "" {
+		return nodeBalancerLBType
+	}
+	return l.loadBalancerType
+}
+
+// claimsService reports whether the CCM provisions the load balancer of
+// service, i.e. whether it has no load balancer class or one of the Linode
+// classes. Services of other classes belong to other implementations.
+func (l *loadbalancers) claimsService(service *v1.Service) bool {
+	return claimsLoadBalancerClass(l.loadBalancerClasses, service)
+}
+
+func claimsLoadBalancerClass(classes map[string]string, service *v1.Service) bool {
+	if service.Spec.LoadBalancerClass == nil {
+		return true
+	}
+	_, ok := classes[*service.Spec.LoadBalancerClass]
+	return ok
+}
+
+// getLoadBalancerType returns the type of load balancer service asks for,
+// which is set by its load balancer class, or by annotation for Services
+// without a class.
+func (l *loadbalancers) getLoadBalancerType(service *v1.Service) (string, error) {
+	if class := service.Spec.LoadBalancerClass; class != nil {
+		lbType, ok := l.loadBalancerClasses[*class]
+		if !ok {
+			return "", fmt.Errorf("load balancer class %s is not handled by the CCM", *class)
+		}
+		return lbType, nil
+	}
+	lbType, ok := service.GetAnnotations()[annotations.AnnLinodeLoadBalancerType]
+	if !ok {
+		return l.defaultLoadBalancerType(), nil
+	}
+	if err := validateLoadBalancerType(lbType); err != nil {
+		return "", fmt.Errorf("annotation %s: %w", annotations.AnnLinodeLoadBalancerType, err)
+	}
+	return lbType, nil
+}
+
+func validateLoadBalancerType(lbType string) error {
+	if !slices.Contains(supportedLoadBalancerTypes, lbType) {
+		return fmt.Errorf("unsupported load balancer type %q, options are %v", lbType, supportedLoadBalancerTypes)
+	}
+	return nil
+}
+
+// getProvisionedLoadBalancerType returns the type of the load balancer
+// serving service, which differs from lbType, the type it asks for, while the
+// Service is migrated.
+//
+// The type is recorded on the Service unless it is the default one, so a
+// Service with an ingress but without the annotation, e.g. provisioned before
+// types could be set per Service, is served by a load balancer of the
+// default type. The class of a Service cannot change, so Services with a
+// class are never migrated.
+func (l *loadbalancers) getProvisionedLoadBalancerType(service *v1.Service, lbType string) string {
+	if service.Spec.LoadBalancerClass != nil {
+		return lbType
+	}
+	if provisionedType, ok := service.GetAnnotations()[annotations.AnnLinodeLoadBalancerProvisionedType]; ok &&
+		validateLoadBalancerType(provisionedType) == nil {
+		return provisionedType
+	}
+	if len(service.Status.LoadBalancer.Ingress) > 0 {
+		return l.defaultLoadBalancerType()
+	}
+	return lbType
+}
+
+// usesNodeBalancer reports whether service is, or is being migrated to or
+// from, a NodeBalancer.
+func (l *loadbalancers) usesNodeBalancer(service *v1.Service) bool {
+	if !l.claimsService(service) {
+		return false
+	}
+	lbType, err := l.getLoadBalancerType(service)
+	if err != nil {
+		lbType = l.defaultLoadBalancerType()
+	}
+	return lbType == nodeBalancerLBType || l.getProvisionedLoadBalancerType(service, lbType) == nodeBalancerLBType
+}
+
+// recordLoadBalancerType records lbType as the type of the load balancer
+// serving service and clears the cutover annotation of its migration.
+func (l *loadbalancers) recordLoadBalancerType(ctx context.Context, service *v1.Service, lbType string) error {
+	if service.Spec.LoadBalancerClass != nil {
+		return nil
+	}
+	serviceAnnotations := service.GetAnnotations()
+	update := map[string]any{}
+	if lbType == l.defaultLoadBalancerType() {
+		if _, ok := serviceAnnotations[annotations.AnnLinodeLoadBalancerProvisionedType]; ok {
+			update[annotations.AnnLinodeLoadBalancerProvisionedType] = nil
+		}
+	} else if serviceAnnotations[annotations.AnnLinodeLoadBalancerProvisionedType] != lbType {
+		update[annotations.AnnLinodeLoadBalancerProvisionedType] = lbType
+	}
+	if _, ok := serviceAnnotations[annotations.AnnLinodeLoadBalancerCutover]; ok {
+		update[annotations.AnnLinodeLoadBalancerCutover] = nil // a null value removes the annotation
+	}
+	if len(update) == 0 {
+		return nil
+	}
+
+	if err := l.retrieveKubeClient(); err != nil {
+		return err
+	}
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{"annotations": update},
+	})
+	if err != nil {
+		return err
+	}
+	if _, err = l.kubeClient.CoreV1().Services(service.Namespace).Patch(ctx, service.Name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("failed to record load balancer type of service (%s): %w", getServiceNn(service), err)
+	}
+	return nil
+}
+
+// migrateLoadBalancer moves service from a load balancer of type from to one
+// of type to without downtime. The new load balancer is provisioned next to
+// the old one and the ingresses of both are published, until the cutover is
+// confirmed by setting the cutover annotation to the new type. The old load
+// balancer is then deleted, and only the ingress of the new one is published.
+func (l *loadbalancers) migrateLoadBalancer(ctx context.Context, clusterName string, service *v1.Service, nodes []*v1.Node, from, to string) (*v1.LoadBalancerStatus, error) {
+	serviceNn := getServiceNn(service)
+	if ip := getRequestedLoadBalancerIP(service); ip != "" {
+		err := fmt.Errorf("service (%s) requests IP %s, which cannot be served by both load balancers, and cannot be migrated from %s to %s", serviceNn, ip, from, to)
+		l.eventf(service, v1.EventTypeWarning, eventReasonLoadBalancerIPFailed, "%s", err)
+		return nil, err
+	}
+
+	oldService, err := l.withLoadBalancerStatus(ctx, clusterName, service, from)
+	if err != nil {
+		sentry.CaptureError(ctx, err)
+		return nil, err
+	}
+
+	if _, err = l.ensureLoadBalancer(ctx, clusterName, service, nodes, to); err != nil {
+		return nil, err
+	}
+	newService, err := l.withLoadBalancerStatus(ctx, clusterName, service, to)
+	if err != nil {
+		sentry.CaptureError(ctx, err)
+		return nil, err
+	}
+
+	cutover := service.GetAnnotations()[annotations.AnnLinodeLoadBalancerCutover] == to
+	if !cutover && len(oldService.Status.LoadBalancer.Ingress) > 0 {
+		if len(newService.Status.LoadBalancer.Ingress) == 0 {
+			// e.g. Cilium has yet to assign the IP of its pool
+			klog.Infof("waiting for the %s load balancer of service (%s) to be ready", to, serviceNn)
+			return &oldService.Status.LoadBalancer, nil
+		}
+		klog.Infof("migrating service (%s) from %s to %s, waiting for the cutover", serviceNn, from, to)
+		l.eventf(service, v1.EventTypeNormal, eventReasonLoadBalancerMigrating,
+			"migrating from %s (%s) to %s (%s), set the %s annotation to %s once traffic has moved",
+			from, ingressAddresses(oldService.Status.LoadBalancer), to, ingressAddresses(newService.Status.LoadBalancer),
+			annotations.AnnLinodeLoadBalancerCutover, to)
+		return mergeLoadBalancerStatus(oldService.Status.LoadBalancer, newService.Status.LoadBalancer), nil
+	}
+
+	if err = l.ensureLoadBalancerDeleted(ctx, clusterName, oldService, from); err != nil {
+		return nil, err
+	}
+	if err = l.recordLoadBalancerType(ctx, service, to); err != nil {
+		return nil, err
+	}
+	klog.Infof("migrated service (%s) from %s to %s", serviceNn, from, to)
+	l.eventf(service, v1.EventTypeNormal, eventReasonLoadBalancerMigrated, "migrated from %s to %s (%s)",
+		from, to, ingressAddresses(newService.Status.LoadBalancer))
+	return &newService.Status.LoadBalancer, nil
+}
+
+// withLoadBalancerStatus returns a copy of service whose status only holds
+// the ingress of its load balancer of type lbType, as the status of a Service
+// being migrated holds the ingresses of both of its load balancers.
+func (l *loadbalancers) withLoadBalancerStatus(ctx context.Context, clusterName string, service *v1.Service, lbType string) (*v1.Service, error) {
+	svc := service.DeepCopy()
+	svc.Status.LoadBalancer = v1.LoadBalancerStatus{}
+
+	if lbType == ciliumLBType {
+		pool, err := l.getCiliumLBIPPool(ctx, service)
+		if k8serrors.IsNotFound(err) {
+			return svc, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, block := range pool.Spec.Blocks {
+			ip, _, _ := strings.Cut(string(block.Cidr), "/")
+			svc.Status.LoadBalancer.Ingress = append(svc.Status.LoadBalancer.Ingress, v1.LoadBalancerIngress{IP: ip})
+		}
+		return svc, nil
+	}
+
+	status, exists, err := l.getLoadBalancer(ctx, clusterName, service, lbType)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		svc.Status.LoadBalancer = *status
+	}
+	return svc, nil
+}
+
+// mergeLoadBalancerStatus returns the ingresses of a followed by those of b
+// that a does not have.
+func mergeLoadBalancerStatus(a, b v1.LoadBalancerStatus) *v1.LoadBalancerStatus {
+	status := a.DeepCopy()
+	for _, ingress := range b.Ingress {
+		if !slices.ContainsFunc(status.Ingress, func(i v1.LoadBalancerIngress) bool {
+			return i.IP == ingress.IP && i.Hostname == ingress.Hostname
+		}) {
+			status.Ingress = append(status.Ingress, ingress)
+		}
+	}
+	return status
+}
+
+func ingressAddresses(status v1.LoadBalancerStatus) string {
+	addresses := make([]string, 0, len(status.Ingress))
+	for _, ingress := range status.Ingress {
+		if ingress.IP != "" {
+			addresses = append(addresses, ingress.IP)
+		} else {
+			addresses = append(addresses, ingress.Hostname)
+		}
+	}
+	return strings.Join(addresses, ", ")
+}
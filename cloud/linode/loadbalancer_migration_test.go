@@ -0,0 +1,169 @@
+package linode
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	k8sClient "github.com/cilium/cilium/pkg/k8s/client"
+	fakev2alpha1 "github.com/cilium/cilium/pkg/k8s/client/clientset/versioned/typed/cilium.io/v2alpha1/fake"
+	"github.com/golang/mock/gomock"
+	"github.com/linode/linodego"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+
+	"github.com/linode/linode-cloud-controller-manager/cloud/annotations"
+	"github.com/linode/linode-cloud-controller-manager/cloud/linode/client/mocks"
+)
+
+func TestGetProvisionedLoadBalancerType(t *testing.T) {
+	ingress := v1.LoadBalancerStatus{Ingress: []v1.LoadBalancerIngress{{IP: "192.0.2.10"}}}
+	testcases := []struct {
+		name        string
+		annotations map[string]string
+		status      v1.LoadBalancerStatus
+		lbType      string
+		expected    string
+	}{
+		{
+			name:     "new service",
+			lbType:   ciliumLBType,
+			expected: ciliumLBType,
+		},
+		{
+			name:     "provisioned service of the default type",
+			status:   ingress,
+			lbType:   ciliumLBType,
+			expected: nodeBalancerLBType,
+		},
+		{
+			name:        "provisioned service of another type",
+			annotations: map[string]string{annotations.AnnLinodeLoadBalancerProvisionedType: ciliumLBType},
+			status:      ingress,
+			lbType:      nodeBalancerLBType,
+			expected:    ciliumLBType,
+		},
+		{
+			name:        "invalid provisioned type",
+			annotations: map[string]string{annotations.AnnLinodeLoadBalancerProvisionedType: "metallb"},
+			status:      ingress,
+			lbType:      ciliumLBType,
+			expected:    nodeBalancerLBType,
+		},
+	}
+
+	lb := &loadbalancers{loadBalancerType: nodeBalancerLBType}
+	for _, test := range testcases {
+		t.Run(test.name, func(t *testing.T) {
+			service := &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{Annotations: test.annotations},
+				Status:     v1.ServiceStatus{LoadBalancer: test.status},
+			}
+			if provisionedType := lb.getProvisionedLoadBalancerType(service, test.lbType); provisionedType != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, provisionedType)
+			}
+		})
+	}
+}
+
+func TestGetLoadBalancerType(t *testing.T) {
+	lb := &loadbalancers{loadBalancerType: ciliumLBType}
+	service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{}}}
+
+	if lbType, err := lb.getLoadBalancerType(service); err != nil || lbType != ciliumLBType {
+		t.Errorf("expected the default type, got %s, %v", lbType, err)
+	}
+	service.Annotations[annotations.AnnLinodeLoadBalancerType] = nodeBalancerLBType
+	if lbType, err := lb.getLoadBalancerType(service); err != nil || lbType != nodeBalancerLBType {
+		t.Errorf("expected %s, got %s, %v", nodeBalancerLBType, lbType, err)
+	}
+	service.Annotations[annotations.AnnLinodeLoadBalancerType] = "metallb"
+	if _, err := lb.getLoadBalancerType(service); err == nil {
+		t.Error("expected an error for an unsupported type")
+	}
+}
+
+func TestMigrateLoadBalancer(t *testing.T) {
+	testcases := []struct {
+		name    string
+		cutover bool
+	}{
+		{name: "before the cutover"},
+		{name: "after the cutover", cutover: true},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mc := mocks.NewMockClient(ctrl)
+			Options.BGPNodeSelector = "cilium-bgp-peering=true"
+
+			nbIP, ciliumIP := "192.0.2.10", "45.76.101.26"
+			nb := linodego.NodeBalancer{ID: 12345, IPv4: &nbIP, Hostname: ptr.To("nb-192-0-2-10.ord.nodebalancer.linode.com")}
+
+			svc := createTestService()
+			svc.Annotations = map[string]string{annotations.AnnLinodeLoadBalancerType: ciliumLBType}
+			if test.cutover {
+				svc.Annotations[annotations.AnnLinodeLoadBalancerCutover] = ciliumLBType
+			}
+			svc.Status.LoadBalancer = *makeLoadBalancerStatus(svc, &nb)
+
+			kubeClient, _ := k8sClient.NewFakeClientset()
+			ciliumClient := &fakev2alpha1.FakeCiliumV2alpha1{Fake: &kubeClient.CiliumFakeClientset.Fake}
+			addService(t, kubeClient, svc)
+			recorder := record.NewFakeRecorder(10)
+			lb := &loadbalancers{client: mc, zone: zone, kubeClient: kubeClient, ciliumClient: ciliumClient, loadBalancerType: nodeBalancerLBType, recorder: recorder}
+
+			// the Cilium pool of the Service has already been created
+			if _, err := lb.createCiliumLBIPPool(context.TODO(), svc, ciliumIP); err != nil {
+				t.Fatal(err)
+			}
+			mc.EXPECT().ListNodeBalancers(gomock.Any(), gomock.Any()).AnyTimes().Return([]linodego.NodeBalancer{nb}, nil)
+			if test.cutover {
+				mc.EXPECT().DeleteNodeBalancer(gomock.Any(), nb.ID).Times(1).Return(nil)
+			}
+
+			status, err := lb.EnsureLoadBalancer(context.TODO(), "linodelb", svc, nodes)
+			if err != nil {
+				t.Fatalf("expected a nil error, got %v", err)
+			}
+
+			var ips []string
+			for _, ingress := range status.Ingress {
+				ips = append(ips, ingress.IP)
+			}
+			expected := []string{nbIP, ciliumIP}
+			reason := eventReasonLoadBalancerMigrating
+			if test.cutover {
+				expected = []string{ciliumIP}
+				reason = eventReasonLoadBalancerMigrated
+			}
+			if strings.Join(ips, ",") != strings.Join(expected, ",") {
+				t.Errorf("expected ingress %v, got %v", expected, ips)
+			}
+			select {
+			case event := <-recorder.Events:
+				if !strings.Contains(event, " "+reason+" ") {
+					t.Errorf("expected a %s event, got %q", reason, event)
+				}
+			default:
+				t.Errorf("expected a %s event", reason)
+			}
+
+			updated, err := kubeClient.CoreV1().Services(svc.Namespace).Get(context.TODO(), svc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			provisionedType, recorded := updated.Annotations[annotations.AnnLinodeLoadBalancerProvisionedType]
+			if recorded != test.cutover || (recorded && provisionedType != ciliumLBType) {
+				t.Errorf("unexpected provisioned type annotation %q", provisionedType)
+			}
+			if _, ok := updated.Annotations[annotations.AnnLinodeLoadBalancerCutover]; ok {
+				t.Error("expected the cutover annotation to be removed")
+			}
+		})
+	}
+}
@@ -2,24 +2,38 @@ package linode
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
 	ciliumclient "github.com/cilium/cilium/pkg/k8s/client/clientset/versioned/typed/cilium.io/v2alpha1"
 	"github.com/linode/linodego"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/exp/slices"
 	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	discoverylisters "k8s.io/client-go/listers/discovery/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/record"
 	cloudprovider "k8s.io/cloud-provider"
 	"k8s.io/klog/v2"
 
@@ -29,7 +43,36 @@ import (
 	"github.com/linode/linode-cloud-controller-manager/sentry"
 )
 
-var errNoNodesAvailable = errors.New("no nodes available for nodebalancer")
+var (
+	errNoNodesAvailable          = errors.New("no nodes available for nodebalancer")
+	errLoadBalancerIPUnavailable = errors.New("requested load balancer IP is unavailable")
+)
+
+const (
+	// NodeBalancer and Cloud Firewall labels must be 3-32 characters long
+	maxLoadBalancerLabelLen  = 32
+	defaultNodeBackendWeight = 100
+	// number of Service UID characters appended to NodeBalancer labels
+	loadBalancerLabelUIDLen = 8
+	// serviceUIDTagPrefix prefixes the tag identifying the Service that owns
+	// a NodeBalancer, so it can be found again without the Service status.
+	serviceUIDTagPrefix = "ccm-uid:"
+	// clusterTagPrefix prefixes the tag identifying the cluster that owns a
+	// NodeBalancer, which unlike the bare cluster name cannot be a user tag.
+	clusterTagPrefix = "ccm-cluster:"
+	// preserveTag marks NodeBalancers that must outlive their Service.
+	preserveTag = "ccm-preserve"
+	// certificates expiring within tlsCertExpiryWarning are reported on the
+	// Service
+	tlsCertExpiryWarning = 30 * 24 * time.Hour
+)
+
+var (
+	// legacyLoadBalancerLabel matches the random labels that were generated
+	// before labels were derived from the Service.
+	legacyLoadBalancerLabel = regexp.MustCompile(`^ccm-[0-9a-f]{12}$`)
+	invalidLabelChars       = regexp.MustCompile(`[^a-zA-Z0-9]+`)
+)
 
 type lbNotFoundError struct {
 	serviceNn      string
@@ -49,36 +92,155 @@ type loadbalancers struct {
 	kubeClient       kubernetes.Interface
 	ciliumClient     ciliumclient.CiliumV2alpha1Interface
 	loadBalancerType string
+	// loadBalancerClasses maps the spec.loadBalancerClass of the Services the
+	// CCM claims to their load balancer type
+	loadBalancerClasses map[string]string
+	// endpointSlices is set once the endpointSliceController is started
+	endpointSlices discoverylisters.EndpointSliceLister
+	// recorder is set once the CCM is initialized
+	recorder record.EventRecorder
+	// ipFeeds are the IP feeds firewall ACLs can reference
+	ipFeeds *firewall.IPFeeds
+	// routeCache holds the VPC IPs of the nodes, shared with the route
+	// controller
+	routeCache *routeCache
 }
 
 type portConfigAnnotation struct {
 	TLSSecretName string `json:"tls-secret-name"`
 	Protocol      string `json:"protocol"`
 	ProxyProtocol string `json:"proxy-protocol"`
+	Check         string `json:"check"`
+	CheckPath     string `json:"check-path"`
+	CheckBody     string `json:"check-body"`
+	CheckInterval *int   `json:"check-interval"`
+	CheckTimeout  *int   `json:"check-timeout"`
+	CheckAttempts *int   `json:"check-attempts"`
+	CheckPassive  *bool  `json:"check-passive"`
+	Algorithm     string `json:"algorithm"`
+	Stickiness    string `json:"stickiness"`
+	CipherSuite   string `json:"cipher-suite"`
 }
 
+// portConfig holds the settings of a single port. The health check and
+// tuning fields are only set when overridden by the port annotation, and
+// otherwise fall back to the Service annotations.
 type portConfig struct {
 	TLSSecretName string
 	Protocol      linodego.ConfigProtocol
 	ProxyProtocol linodego.ConfigProxyProtocol
 	Port          int
+	Check         linodego.ConfigCheck
+	CheckPath     string
+	CheckBody     string
+	CheckInterval *int
+	CheckTimeout  *int
+	CheckAttempts *int
+	CheckPassive  *bool
+	Algorithm     linodego.ConfigAlgorithm
+	Stickiness    linodego.ConfigStickiness
+	CipherSuite   linodego.ConfigCipher
 }
 
 // newLoadbalancers returns a cloudprovider.LoadBalancer whose concrete type is a *loadbalancer.
 func newLoadbalancers(client client.Client, zone string) cloudprovider.LoadBalancer {
-	return &loadbalancers{client: client, zone: zone, loadBalancerType: Options.LoadBalancerType}
+	return &loadbalancers{client: client, zone: zone, loadBalancerType: Options.LoadBalancerType, loadBalancerClasses: Options.LoadBalancerClasses}
 }
 
-func (l *loadbalancers) getNodeBalancerForService(ctx context.Context, service *v1.Service) (*linodego.NodeBalancer, error) {
-	rawID := service.GetAnnotations()[annotations.AnnLinodeNodeBalancerID]
-	id, idErr := strconv.Atoi(rawID)
-	hasIDAnn := idErr == nil && id != 0
-
-	if hasIDAnn {
-		sentry.SetTag(ctx, "load_balancer_id", rawID)
+func (l *loadbalancers) getNodeBalancerForService(ctx context.Context, clusterName string, service *v1.Service) (*linodego.NodeBalancer, error) {
+	id, err := annotations.GetInt(service, annotations.AnnLinodeNodeBalancerID, 0)
+	if err != nil {
+		return nil, l.invalidAnnotation(service, err)
+	}
+	if id != 0 {
+		sentry.SetTag(ctx, "load_balancer_id", strconv.Itoa(id))
 		return l.getNodeBalancerByID(ctx, service, id)
 	}
-	return l.getNodeBalancerByStatus(ctx, service)
+
+	nb, err := l.getNodeBalancerByStatus(ctx, service)
+	if _, ok := err.(lbNotFoundError); !ok {
+		return nb, err
+	}
+
+	// The status is missing or stale, e.g. after the Service was restored.
+	// Look for a NodeBalancer owned by this Service before giving up, so that
+	// it is adopted instead of being replaced by a new one.
+	nb, err = l.getNodeBalancerByServiceUID(ctx, service)
+	if _, ok := err.(lbNotFoundError); !ok {
+		return nb, err
+	}
+	if ip := getRequestedLoadBalancerIP(service); ip != "" {
+		return l.getNodeBalancerByRequestedIP(ctx, clusterName, service, ip)
+	}
+	return l.getNodeBalancerByLabel(ctx, service, l.GetLoadBalancerName(ctx, clusterName, service))
+}
+
+// getRequestedLoadBalancerIP returns the IP service asks to be reachable on,
+// if any.
+func getRequestedLoadBalancerIP(service *v1.Service) string {
+	if ip, ok := service.GetAnnotations()[annotations.AnnLinodeLoadBalancerIP]; ok {
+		return strings.TrimSpace(ip)
+	}
+	return service.Spec.LoadBalancerIP
+}
+
+// getNodeBalancerByRequestedIP returns the NodeBalancer with the IP service
+// asks for, so that it can be adopted, e.g. when a Service is recreated with
+// the IP of the NodeBalancer preserved from its previous incarnation.
+// Only NodeBalancers of this cluster whose owning Service no longer exists can
+// be adopted, whether they are preserved or not.
+func (l *loadbalancers) getNodeBalancerByRequestedIP(ctx context.Context, clusterName string, service *v1.Service, ip string) (*linodego.NodeBalancer, error) {
+	nb, err := l.getNodeBalancerByIP(ctx, service, ip)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(nb.Tags, clusterTag(clusterName)) {
+		return nil, fmt.Errorf("%w: NodeBalancer (%d) with IP %s is not tagged %s and may belong to another cluster", errLoadBalancerIPUnavailable, nb.ID, ip, clusterTag(clusterName))
+	}
+	for _, tag := range nb.Tags {
+		uid, ok := strings.CutPrefix(tag, serviceUIDTagPrefix)
+		if !ok || uid == string(service.UID) {
+			continue
+		}
+		live, err := l.serviceExists(ctx, types.UID(uid))
+		if err != nil {
+			return nil, err
+		}
+		if live {
+			return nil, fmt.Errorf("%w: NodeBalancer (%d) with IP %s belongs to another Service", errLoadBalancerIPUnavailable, nb.ID, ip)
+		}
+	}
+	return nb, nil
+}
+
+// serviceExists reports whether a Service with the given UID exists.
+func (l *loadbalancers) serviceExists(ctx context.Context, uid types.UID) (bool, error) {
+	if err := l.retrieveKubeClient(); err != nil {
+		return false, err
+	}
+	services, err := l.kubeClient.CoreV1().Services("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return false, err
+	}
+	for _, service := range services.Items {
+		if service.UID == uid {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// checkLoadBalancerIP returns an error if nb does not have the IP service
+// asks for, as the IPs of a NodeBalancer cannot be changed.
+func checkLoadBalancerIP(service *v1.Service, nb *linodego.NodeBalancer) error {
+	ip := getRequestedLoadBalancerIP(service)
+	if ip == "" {
+		return nil
+	}
+	if (nb.IPv4 != nil && *nb.IPv4 == ip) || (nb.IPv6 != nil && strings.TrimSuffix(*nb.IPv6, "/128") == ip) {
+		return nil
+	}
+	return fmt.Errorf("%w: NodeBalancer (%d) does not have IP %s and its IPs cannot be changed, recreate the Service to use another NodeBalancer", errLoadBalancerIPUnavailable, nb.ID, ip)
 }
 
 func (l *loadbalancers) getLatestServiceLoadBalancerStatus(ctx context.Context, service *v1.Service) (v1.LoadBalancerStatus, error) {
@@ -99,7 +261,7 @@ func (l *loadbalancers) getLatestServiceLoadBalancerStatus(ctx context.Context,
 func (l *loadbalancers) getNodeBalancerByStatus(ctx context.Context, service *v1.Service) (nb *linodego.NodeBalancer, err error) {
 	for _, ingress := range service.Status.LoadBalancer.Ingress {
 		if ingress.IP != "" {
-			return l.getNodeBalancerByIPv4(ctx, service, ingress.IP)
+			return l.getNodeBalancerByIP(ctx, service, ingress.IP)
 		}
 		if ingress.Hostname != "" {
 			return l.getNodeBalancerByHostname(ctx, service, ingress.Hostname)
@@ -113,7 +275,7 @@ func (l *loadbalancers) getNodeBalancerByStatus(ctx context.Context, service *v1
 // The current NodeBalancer from getNodeBalancerForService is compared to the most recent
 // LoadBalancer status; if they are different (because of an updated NodeBalancerID
 // annotation), the old one is deleted.
-func (l *loadbalancers) cleanupOldNodeBalancer(ctx context.Context, service *v1.Service) error {
+func (l *loadbalancers) cleanupOldNodeBalancer(ctx context.Context, clusterName string, service *v1.Service) error {
 	// unless there's an annotation, we can never get a past and current NB to differ,
 	// because they're looked up the same way
 	if _, ok := service.GetAnnotations()[annotations.AnnLinodeNodeBalancerID]; !ok {
@@ -131,7 +293,7 @@ func (l *loadbalancers) cleanupOldNodeBalancer(ctx context.Context, service *v1.
 		return err
 	}
 
-	nb, err := l.getNodeBalancerForService(ctx, service)
+	nb, err := l.getNodeBalancerForService(ctx, clusterName, service)
 	if err != nil {
 		return err
 	}
@@ -150,10 +312,49 @@ func (l *loadbalancers) cleanupOldNodeBalancer(ctx context.Context, service *v1.
 
 // GetLoadBalancerName returns the name of the load balancer.
 //
+// The name is derived from the cluster name, the Service's namespace and name,
+// and a prefix of the Service's UID, so that it is stable across calls and
+// identifies the Service in Cloud Manager. It is used as the NodeBalancer and
+// firewall label, so it is trimmed to fit Linode's label limits.
+//
 // GetLoadBalancer will not modify service.
-func (l *loadbalancers) GetLoadBalancerName(_ context.Context, _ string, _ *v1.Service) string {
-	unixNano := strconv.FormatInt(time.Now().UnixNano(), 16)
-	return fmt.Sprintf("ccm-%s", unixNano[len(unixNano)-12:])
+func (l *loadbalancers) GetLoadBalancerName(_ context.Context, clusterName string, service *v1.Service) string {
+	uid := invalidLabelChars.ReplaceAllString(string(service.UID), "")
+	if len(uid) > loadBalancerLabelUIDLen {
+		uid = uid[:loadBalancerLabelUIDLen]
+	}
+
+	parts := make([]string, 0, 3)
+	for _, part := range []string{clusterName, service.Namespace, service.Name} {
+		if part = strings.Trim(invalidLabelChars.ReplaceAllString(part, "-"), "-"); part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	budget := maxLoadBalancerLabelLen
+	if uid != "" {
+		budget -= len(uid) + 1
+	}
+	// shorten the longest part until everything fits, so that no single
+	// part crowds out the others
+	for len(strings.Join(parts, "-")) > budget {
+		longest := 0
+		for i := range parts {
+			if len(parts[i]) > len(parts[longest]) {
+				longest = i
+			}
+		}
+		parts[longest] = parts[longest][:len(parts[longest])-1]
+	}
+	for i := range parts {
+		parts[i] = strings.Trim(parts[i], "-")
+	}
+	if uid != "" {
+		parts = append(parts, uid)
+	}
+
+	label := strings.Join(slices.DeleteFunc(parts, func(p string) bool { return p == "" }), "-")
+	return coerceString(label, 3, maxLoadBalancerLabelLen, "ccm-")
 }
 
 // GetLoadBalancer returns the *v1.LoadBalancerStatus of service.
@@ -164,14 +365,24 @@ func (l *loadbalancers) GetLoadBalancer(ctx context.Context, clusterName string,
 	sentry.SetTag(ctx, "cluster_name", clusterName)
 	sentry.SetTag(ctx, "service", service.Name)
 
+	// report the load balancer serving service, which during a migration is
+	// the one being migrated from
+	lbType, err := l.getLoadBalancerType(service)
+	if err != nil {
+		lbType = l.defaultLoadBalancerType()
+	}
+	return l.getLoadBalancer(ctx, clusterName, service, l.getProvisionedLoadBalancerType(service, lbType))
+}
+
+func (l *loadbalancers) getLoadBalancer(ctx context.Context, clusterName string, service *v1.Service, lbType string) (*v1.LoadBalancerStatus, bool, error) {
 	// Handle LoadBalancers backed by Cilium
-	if l.loadBalancerType == ciliumLBType {
+	if lbType == ciliumLBType {
 		return &v1.LoadBalancerStatus{
 			Ingress: service.Status.LoadBalancer.Ingress,
 		}, true, nil
 	}
 
-	nb, err := l.getNodeBalancerForService(ctx, service)
+	nb, err := l.getNodeBalancerForService(ctx, clusterName, service)
 	switch err.(type) {
 	case nil:
 		break
@@ -195,10 +406,27 @@ func (l *loadbalancers) EnsureLoadBalancer(ctx context.Context, clusterName stri
 	ctx = sentry.SetHubOnContext(ctx)
 	sentry.SetTag(ctx, "cluster_name", clusterName)
 	sentry.SetTag(ctx, "service", service.Name)
+
+	lbType, err := l.getLoadBalancerType(service)
+	if err != nil {
+		return nil, l.invalidAnnotation(service, err)
+	}
+	if provisionedType := l.getProvisionedLoadBalancerType(service, lbType); provisionedType != lbType {
+		return l.migrateLoadBalancer(ctx, clusterName, service, nodes, provisionedType, lbType)
+	}
+	// record the type before provisioning, so that the load balancer is not
+	// mistaken for one of the default type once its ingress is published
+	if err = l.recordLoadBalancerType(ctx, service, lbType); err != nil {
+		return nil, err
+	}
+	return l.ensureLoadBalancer(ctx, clusterName, service, nodes, lbType)
+}
+
+func (l *loadbalancers) ensureLoadBalancer(ctx context.Context, clusterName string, service *v1.Service, nodes []*v1.Node, lbType string) (lbStatus *v1.LoadBalancerStatus, err error) {
 	serviceNn := getServiceNn(service)
 
 	// Handle LoadBalancers backed by Cilium
-	if l.loadBalancerType == ciliumLBType {
+	if lbType == ciliumLBType {
 		klog.Infof("handling LoadBalancer Service %s as %s", serviceNn, ciliumLBClass)
 
 		if err = l.ensureCiliumBGPPeeringPolicy(ctx); err != nil {
@@ -230,8 +458,11 @@ func (l *loadbalancers) EnsureLoadBalancer(ctx context.Context, clusterName stri
 
 		// CiliumLoadBalancerIPPool does not yet exist for the service
 		var sharedIP string
-		if sharedIP, err = l.createSharedIP(ctx, nodes, ipHolderSuffix); err != nil {
+		if sharedIP, err = l.createSharedIP(ctx, nodes, ipHolderSuffix, getRequestedLoadBalancerIP(service)); err != nil {
 			klog.Errorf("Failed to request shared instance IP: %s", err.Error())
+			if errors.Is(err, errLoadBalancerIPUnavailable) {
+				l.eventf(service, v1.EventTypeWarning, eventReasonLoadBalancerIPFailed, "%s", err)
+			}
 			return nil, err
 		}
 		if _, err = l.createCiliumLBIPPool(ctx, service, sharedIP); err != nil {
@@ -248,7 +479,7 @@ func (l *loadbalancers) EnsureLoadBalancer(ctx context.Context, clusterName stri
 	// Handle LoadBalancers backed by NodeBalancers
 	var nb *linodego.NodeBalancer
 
-	nb, err = l.getNodeBalancerForService(ctx, service)
+	nb, err = l.getNodeBalancerForService(ctx, clusterName, service)
 	switch err.(type) {
 	case lbNotFoundError:
 		if service.GetAnnotations()[annotations.AnnLinodeNodeBalancerID] != "" {
@@ -258,19 +489,33 @@ func (l *loadbalancers) EnsureLoadBalancer(ctx context.Context, clusterName stri
 			return nil, err
 		}
 
+		if ip := getRequestedLoadBalancerIP(service); ip != "" {
+			err = fmt.Errorf("%w: no NodeBalancer has IP %s and NodeBalancers cannot be created with a given IP", errLoadBalancerIPUnavailable, ip)
+			l.eventf(service, v1.EventTypeWarning, eventReasonLoadBalancerIPFailed, "%s", err)
+			return nil, err
+		}
+
 		if nb, err = l.buildLoadBalancerRequest(ctx, clusterName, service, nodes); err != nil {
 			sentry.CaptureError(ctx, err)
 			return nil, err
 		}
 		klog.Infof("created new NodeBalancer (%d) for service (%s)", nb.ID, serviceNn)
+		l.eventf(service, v1.EventTypeNormal, eventReasonNodeBalancerCreated, "created NodeBalancer %d", nb.ID)
 
 	case nil:
+		if err = checkLoadBalancerIP(service, nb); err != nil {
+			l.eventf(service, v1.EventTypeWarning, eventReasonLoadBalancerIPFailed, "%s", err)
+			return nil, err
+		}
 		if err = l.updateNodeBalancer(ctx, clusterName, service, nodes, nb); err != nil {
 			sentry.CaptureError(ctx, err)
 			return nil, err
 		}
 
 	default:
+		if errors.Is(err, errLoadBalancerIPUnavailable) {
+			l.eventf(service, v1.EventTypeWarning, eventReasonLoadBalancerIPFailed, "%s", err)
+		}
 		sentry.CaptureError(ctx, err)
 		return nil, err
 	}
@@ -279,7 +524,7 @@ func (l *loadbalancers) EnsureLoadBalancer(ctx context.Context, clusterName stri
 	lbStatus = makeLoadBalancerStatus(service, nb)
 
 	if !l.shouldPreserveNodeBalancer(service) {
-		if err := l.cleanupOldNodeBalancer(ctx, service); err != nil {
+		if err := l.cleanupOldNodeBalancer(ctx, clusterName, service); err != nil {
 			sentry.CaptureError(ctx, err)
 			return nil, err
 		}
@@ -297,10 +542,29 @@ func (l *loadbalancers) updateNodeBalancer(
 	nb *linodego.NodeBalancer,
 ) (err error) {
 	if len(nodes) == 0 {
+		l.eventf(service, v1.EventTypeWarning, eventReasonNoNodesAvailable, "no nodes available to back NodeBalancer %d", nb.ID)
+		return fmt.Errorf("%w: service %s", errNoNodesAvailable, getServiceNn(service))
+	}
+
+	if err = validateServicePorts(service); err != nil {
+		sentry.CaptureError(ctx, err)
+		return err
+	}
+	nodes = l.getLocalTrafficNodes(service, nodes)
+	vpc, err := l.getNodeBalancerVPC(ctx, service, nodes, nb)
+	if err != nil {
+		sentry.CaptureError(ctx, err)
+		return err
+	}
+	if nodes = vpc.filterNodes(nodes); len(nodes) == 0 {
+		l.eventf(service, v1.EventTypeWarning, eventReasonNoNodesAvailable, "no nodes in subnet %d to back NodeBalancer %d", vpc.subnetID, nb.ID)
 		return fmt.Errorf("%w: service %s", errNoNodesAvailable, getServiceNn(service))
 	}
 
-	connThrottle := getConnectionThrottle(service)
+	connThrottle, err := getConnectionThrottle(service)
+	if err != nil {
+		return l.invalidAnnotation(service, err)
+	}
 	if connThrottle != nb.ClientConnThrottle {
 		update := nb.GetUpdateOptions()
 		update.ClientConnThrottle = &connThrottle
@@ -322,9 +586,15 @@ func (l *loadbalancers) updateNodeBalancer(
 		}
 	}
 
-	fwClient := firewall.LinodeClient{Client: l.client}
-	err = fwClient.UpdateNodeBalancerFirewall(ctx, l.GetLoadBalancerName(ctx, clusterName, service), tags, service, nb)
-	if err != nil {
+	label := l.GetLoadBalancerName(ctx, clusterName, service)
+	if nb.Label != nil && *nb.Label != label && legacyLoadBalancerLabel.MatchString(*nb.Label) {
+		if nb, err = l.migrateLegacyLabel(ctx, nb, label); err != nil {
+			sentry.CaptureError(ctx, err)
+			return err
+		}
+	}
+
+	if err = l.updateNodeBalancerFirewall(ctx, label, tags, service, nb); err != nil {
 		return err
 	}
 
@@ -341,20 +611,24 @@ func (l *loadbalancers) updateNodeBalancer(
 		return err
 	}
 
+	// Nodes that are being drained, listed once the first config needs them
+	var drainingNodes []*v1.Node
+
+	// Fingerprints of the certificates of HTTPS ports, by port
+	recordedFingerprints := getTLSFingerprints(service)
+	fingerprints := make(map[int]string)
+
 	// Add or overwrite configs for each of the Service's ports
 	for _, port := range service.Spec.Ports {
-		if port.Protocol == v1.ProtocolUDP {
-			err := fmt.Errorf("error updating NodeBalancer Config: ports with the UDP protocol are not supported")
-			sentry.CaptureError(ctx, err)
-			return err
-		}
-
 		// Construct a new config for this port
 		newNBCfg, err := l.buildNodeBalancerConfig(ctx, service, int(port.Port))
 		if err != nil {
 			sentry.CaptureError(ctx, err)
 			return err
 		}
+		if newNBCfg.SSLFingerprint != "" {
+			fingerprints[newNBCfg.Port] = newNBCfg.SSLFingerprint
+		}
 
 		// Look for an existing config for this port
 		var currentNBCfg *linodego.NodeBalancerConfig
@@ -365,14 +639,30 @@ func (l *loadbalancers) updateNodeBalancer(
 				break
 			}
 		}
+
+		// A config cannot be rebuilt between UDP and a TCP-based protocol, so
+		// replace it entirely when the port switches sides.
+		if currentNBCfg != nil && (currentNBCfg.Protocol == linodego.ProtocolUDP) != (newNBCfg.Protocol == linodego.ProtocolUDP) {
+			klog.Infof("NodeBalancer %d config for port %d changes protocol from %s to %s, recreating it",
+				nb.ID, port.Port, currentNBCfg.Protocol, newNBCfg.Protocol)
+			if err = l.client.DeleteNodeBalancerConfig(ctx, nb.ID, currentNBCfg.ID); err != nil {
+				sentry.CaptureError(ctx, err)
+				return fmt.Errorf("[port %d] error deleting NodeBalancer config: %v", int(port.Port), err)
+			}
+			currentNBCfg = nil
+		}
 		oldNBNodeIDs := make(map[string]int)
+		var currentNBNodes []linodego.NodeBalancerNode
+		currentNBNodesListed := false
 		if currentNBCfg != nil {
 			// Obtain list of current NB nodes and convert it to map of node IDs
-			currentNBNodes, err := l.client.ListNodeBalancerNodes(ctx, nb.ID, currentNBCfg.ID, nil)
+			currentNBNodes, err = l.client.ListNodeBalancerNodes(ctx, nb.ID, currentNBCfg.ID, nil)
 			if err != nil {
 				// This error can be ignored, because if we fail to get nodes we can anyway rebuild the config from scratch,
 				// it would just cause the NB to reload config even if the node list did not change, so we prefer to send IDs when it is posible.
 				klog.Warningf("Unable to list existing nodebalancer nodes for NB %d config %d, error: %s", nb.ID, newNBCfg.ID, err)
+			} else {
+				currentNBNodesListed = true
 			}
 			for _, node := range currentNBNodes {
 				oldNBNodeIDs[node.Address] = node.ID
@@ -384,16 +674,46 @@ func (l *loadbalancers) updateNodeBalancer(
 		// Add all of the Nodes to the config
 		newNBNodes := make([]linodego.NodeBalancerConfigRebuildNodeOptions, 0, len(nodes))
 		for _, node := range nodes {
-			newNodeOpts := l.buildNodeBalancerNodeConfigRebuildOptions(node, port.NodePort)
+			newNodeOpts := l.buildNodeBalancerNodeConfigRebuildOptions(node, port.NodePort, vpc)
 			oldNodeID, ok := oldNBNodeIDs[newNodeOpts.Address]
 			if ok {
 				newNodeOpts.ID = oldNodeID
+				delete(oldNBNodeIDs, newNodeOpts.Address)
 			} else {
 				klog.Infof("No preexisting node id for %v found.", newNodeOpts.Address)
 			}
 			newNBNodes = append(newNBNodes, newNodeOpts)
 		}
 
+		// Backends of nodes that are no longer passed in but are still being
+		// drained are kept, in drain mode, until the node is uncordoned or deleted.
+		if len(oldNBNodeIDs) > 0 && drainingNodes == nil {
+			if drainingNodes, err = l.getDrainingNodes(ctx); err != nil {
+				klog.Warningf("Unable to list draining nodes, removing their backends from NB %d: %s", nb.ID, err)
+				drainingNodes = []*v1.Node{}
+			}
+			drainingNodes = vpc.filterNodes(drainingNodes)
+		}
+		for _, node := range drainingNodes {
+			drainOpts := l.buildNodeBalancerNodeConfigRebuildOptions(node, port.NodePort, vpc)
+			if oldNodeID, ok := oldNBNodeIDs[drainOpts.Address]; ok {
+				klog.Infof("Draining backend %s of NB %d", drainOpts.Address, nb.ID)
+				drainOpts.ID = oldNodeID
+				drainOpts.Mode = linodego.ModeDrain
+				newNBNodes = append(newNBNodes, drainOpts)
+				delete(oldNBNodeIDs, drainOpts.Address)
+			}
+		}
+
+		// Rebuilding a config reloads the NodeBalancer, so only do it on drift
+		if currentNBCfg != nil && currentNBNodesListed &&
+			!nodeBalancerConfigChanged(currentNBCfg, &newNBCfg, recordedFingerprints[newNBCfg.Port]) &&
+			!nodeBalancerNodesChanged(currentNBNodes, newNBNodes) {
+			klog.V(3).Infof("NodeBalancer %d config for port %d is up to date, skipping rebuild", nb.ID, port.Port)
+			nodeBalancerConfigRebuildsSkipped.Inc()
+			continue
+		}
+
 		// If there's no existing config, create it
 		var rebuildOpts linodego.NodeBalancerConfigRebuildOptions
 		if currentNBCfg == nil {
@@ -420,19 +740,71 @@ func (l *loadbalancers) updateNodeBalancer(
 			sentry.CaptureError(ctx, err)
 			return fmt.Errorf("[port %d] error rebuilding NodeBalancer config: %v", int(port.Port), err)
 		}
+		l.eventf(service, v1.EventTypeNormal, eventReasonNodeBalancerConfigRebuilt, "rebuilt NodeBalancer %d config for port %d with %d backends", nb.ID, port.Port, len(newNBNodes))
 	}
 
+	l.recordTLSFingerprints(ctx, service, fingerprints)
 	return nil
 }
 
+// migrateLegacyLabel renames a NodeBalancer that still carries a randomly
+// generated label, along with any firewall the CCM created under that label.
+func (l *loadbalancers) migrateLegacyLabel(ctx context.Context, nb *linodego.NodeBalancer, label string) (*linodego.NodeBalancer, error) {
+	oldLabel := *nb.Label
+
+	firewalls, err := l.client.ListNodeBalancerFirewalls(ctx, nb.ID, &linodego.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, fw := range firewalls {
+		if fw.Label != oldLabel {
+			continue
+		}
+		if _, err = l.client.UpdateFirewall(ctx, fw.ID, linodego.FirewallUpdateOptions{Label: label}); err != nil {
+			return nil, err
+		}
+		klog.Infof("renamed firewall (%d) from %s to %s", fw.ID, oldLabel, label)
+	}
+
+	update := nb.GetUpdateOptions()
+	update.Label = &label
+	if nb, err = l.client.UpdateNodeBalancer(ctx, nb.ID, update); err != nil {
+		return nil, err
+	}
+	klog.Infof("renamed NodeBalancer (%d) from %s to %s", nb.ID, oldLabel, label)
+	return nb, nil
+}
+
 // UpdateLoadBalancer updates the NodeBalancer to have configs that match the Service's ports
 func (l *loadbalancers) UpdateLoadBalancer(ctx context.Context, clusterName string, service *v1.Service, nodes []*v1.Node) (err error) {
 	ctx = sentry.SetHubOnContext(ctx)
 	sentry.SetTag(ctx, "cluster_name", clusterName)
 	sentry.SetTag(ctx, "service", service.Name)
 
+	lbType, err := l.getLoadBalancerType(service)
+	if err != nil {
+		return l.invalidAnnotation(service, err)
+	}
+	// UpdateLoadBalancer is invoked with a nil LoadBalancerStatus; we must fetch the latest
+	// status to tell whether the Service is being migrated.
+	serviceWithStatus := service.DeepCopy()
+	serviceWithStatus.Status.LoadBalancer, err = l.getLatestServiceLoadBalancerStatus(ctx, service)
+	if err != nil {
+		return fmt.Errorf("failed to get latest LoadBalancer status for service (%s): %s", getServiceNn(service), err)
+	}
+
+	// keep the load balancer being migrated from up to date until the cutover
+	if provisionedType := l.getProvisionedLoadBalancerType(serviceWithStatus, lbType); provisionedType != lbType {
+		if err = l.updateLoadBalancer(ctx, clusterName, service, serviceWithStatus, nodes, provisionedType); err != nil {
+			return err
+		}
+	}
+	return l.updateLoadBalancer(ctx, clusterName, service, serviceWithStatus, nodes, lbType)
+}
+
+func (l *loadbalancers) updateLoadBalancer(ctx context.Context, clusterName string, service, serviceWithStatus *v1.Service, nodes []*v1.Node, lbType string) error {
 	// handle LoadBalancers backed by Cilium
-	if l.loadBalancerType == ciliumLBType {
+	if lbType == ciliumLBType {
 		klog.Infof("handling update for LoadBalancer Service %s/%s as %s", service.Namespace, service.Name, ciliumLBClass)
 		serviceNn := getServiceNn(service)
 		var ipHolderSuffix string
@@ -450,22 +822,14 @@ func (l *loadbalancers) UpdateLoadBalancer(ctx context.Context, clusterName stri
 		return nil
 	}
 
-	// UpdateLoadBalancer is invoked with a nil LoadBalancerStatus; we must fetch the latest
-	// status for NodeBalancer discovery.
-	serviceWithStatus := service.DeepCopy()
-	serviceWithStatus.Status.LoadBalancer, err = l.getLatestServiceLoadBalancerStatus(ctx, service)
-	if err != nil {
-		return fmt.Errorf("failed to get latest LoadBalancer status for service (%s): %s", getServiceNn(service), err)
-	}
-
-	nb, err := l.getNodeBalancerForService(ctx, serviceWithStatus)
+	nb, err := l.getNodeBalancerForService(ctx, clusterName, serviceWithStatus)
 	if err != nil {
 		sentry.CaptureError(ctx, err)
 		return err
 	}
 
 	if !l.shouldPreserveNodeBalancer(service) {
-		if err := l.cleanupOldNodeBalancer(ctx, service); err != nil {
+		if err := l.cleanupOldNodeBalancer(ctx, clusterName, service); err != nil {
 			sentry.CaptureError(ctx, err)
 			return err
 		}
@@ -509,8 +873,38 @@ func (l *loadbalancers) EnsureLoadBalancerDeleted(ctx context.Context, clusterNa
 	sentry.SetTag(ctx, "cluster_name", clusterName)
 	sentry.SetTag(ctx, "service", service.Name)
 
+	if !l.claimsService(service) {
+		klog.V(3).Infof("ignoring deletion of service (%s) of load balancer class %s", getServiceNn(service), *service.Spec.LoadBalancerClass)
+		return nil
+	}
+
+	// an invalid type annotation must not prevent the deletion of the load
+	// balancer that was provisioned
+	lbType, err := l.getLoadBalancerType(service)
+	if err != nil {
+		lbType = l.defaultLoadBalancerType()
+	}
+	provisionedType := l.getProvisionedLoadBalancerType(service, lbType)
+	if provisionedType == lbType {
+		return l.ensureLoadBalancerDeleted(ctx, clusterName, service, lbType)
+	}
+
+	// delete both load balancers of a Service deleted during a migration
+	for _, t := range []string{provisionedType, lbType} {
+		svc, err := l.withLoadBalancerStatus(ctx, clusterName, service, t)
+		if err != nil {
+			return err
+		}
+		if err = l.ensureLoadBalancerDeleted(ctx, clusterName, svc, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *loadbalancers) ensureLoadBalancerDeleted(ctx context.Context, clusterName string, service *v1.Service, lbType string) error {
 	// Handle LoadBalancers backed by Cilium
-	if l.loadBalancerType == ciliumLBType {
+	if lbType == ciliumLBType {
 		klog.Infof("handling LoadBalancer Service %s/%s as %s", service.Namespace, service.Name, ciliumLBClass)
 		if err := l.deleteSharedIP(ctx, service); err != nil {
 			return err
@@ -527,13 +921,14 @@ func (l *loadbalancers) EnsureLoadBalancerDeleted(ctx context.Context, clusterNa
 	// Handle LoadBalancers backed by NodeBalancers
 
 	serviceNn := getServiceNn(service)
+	tlsCertificateDaysUntilExpiry.DeletePartialMatch(prometheus.Labels{"namespace": service.Namespace, "service": service.Name})
 
 	if len(service.Status.LoadBalancer.Ingress) == 0 {
 		klog.Infof("short-circuiting deletion of NodeBalancer for service(%s) as LoadBalancer ingress is not present", serviceNn)
 		return nil
 	}
 
-	nb, err := l.getNodeBalancerForService(ctx, service)
+	nb, err := l.getNodeBalancerForService(ctx, clusterName, service)
 	switch getErr := err.(type) {
 	case nil:
 		break
@@ -555,10 +950,12 @@ func (l *loadbalancers) EnsureLoadBalancerDeleted(ctx context.Context, clusterNa
 			serviceNn,
 			annotations.AnnLinodeLoadBalancerPreserve,
 		)
+		l.eventf(service, v1.EventTypeNormal, eventReasonNodeBalancerPreserved,
+			"preserved NodeBalancer %d as the service is annotated with %s", nb.ID, annotations.AnnLinodeLoadBalancerPreserve)
 		return nil
 	}
 
-	fwClient := firewall.LinodeClient{Client: l.client}
+	fwClient := firewall.LinodeClient{Client: l.client, Recorder: l.recorder}
 	if err = fwClient.DeleteNodeBalancerFirewall(ctx, service, nb); err != nil {
 		return err
 	}
@@ -573,6 +970,27 @@ func (l *loadbalancers) EnsureLoadBalancerDeleted(ctx context.Context, clusterNa
 	return nil
 }
 
+// updateNodeBalancerFirewall reconciles the firewall of nb with the firewall
+// annotations of service.
+func (l *loadbalancers) updateNodeBalancerFirewall(ctx context.Context, label string, tags []string, service *v1.Service, nb *linodego.NodeBalancer) error {
+	aclService, err := l.withFirewallACL(ctx, service)
+	if err != nil {
+		return err
+	}
+
+	fwClient := firewall.LinodeClient{Client: l.client, Recorder: l.recorder, Feeds: l.ipFeeds}
+	err = fwClient.UpdateNodeBalancerFirewall(ctx, label, tags, aclService, nb)
+	var parseErr *annotations.ParseError
+	if errors.As(err, &parseErr) || errors.Is(err, firewall.ErrInvalidFWConfig) {
+		return l.invalidAnnotation(service, err)
+	}
+	if err != nil {
+		l.eventf(service, v1.EventTypeWarning, eventReasonFirewallFailed, "failed to update the firewall of NodeBalancer %d: %s", nb.ID, err)
+		return err
+	}
+	return nil
+}
+
 func (l *loadbalancers) getNodeBalancerByHostname(ctx context.Context, service *v1.Service, hostname string) (*linodego.NodeBalancer, error) {
 	lbs, err := l.client.ListNodeBalancers(ctx, nil)
 	if err != nil {
@@ -587,8 +1005,15 @@ func (l *loadbalancers) getNodeBalancerByHostname(ctx context.Context, service *
 	return nil, lbNotFoundError{serviceNn: getServiceNn(service)}
 }
 
-func (l *loadbalancers) getNodeBalancerByIPv4(ctx context.Context, service *v1.Service, ipv4 string) (*linodego.NodeBalancer, error) {
-	filter := fmt.Sprintf(`{"ipv4": "%v"}`, ipv4)
+// getNodeBalancerByServiceUID attempts to get the NodeBalancer tagged with the
+// service's UID.
+func (l *loadbalancers) getNodeBalancerByServiceUID(ctx context.Context, service *v1.Service) (*linodego.NodeBalancer, error) {
+	if service.UID == "" {
+		return nil, lbNotFoundError{serviceNn: getServiceNn(service)}
+	}
+
+	tag := serviceUIDTag(service)
+	filter := fmt.Sprintf(`{"tags": "%v"}`, tag)
 	lbs, err := l.client.ListNodeBalancers(ctx, &linodego.ListOptions{Filter: filter})
 	if err != nil {
 		return nil, err
@@ -596,7 +1021,41 @@ func (l *loadbalancers) getNodeBalancerByIPv4(ctx context.Context, service *v1.S
 	if len(lbs) == 0 {
 		return nil, lbNotFoundError{serviceNn: getServiceNn(service)}
 	}
-	klog.V(2).Infof("found NodeBalancer (%d) for service (%s) via IPv4 (%s)", lbs[0].ID, getServiceNn(service), ipv4)
+	if len(lbs) > 1 {
+		klog.Warningf("found %d NodeBalancers tagged %s for service (%s), adopting (%d)", len(lbs), tag, getServiceNn(service), lbs[0].ID)
+	}
+	klog.Infof("found NodeBalancer (%d) for service (%s) via tag (%s)", lbs[0].ID, getServiceNn(service), tag)
+	return &lbs[0], nil
+}
+
+func (l *loadbalancers) getNodeBalancerByLabel(ctx context.Context, service *v1.Service, label string) (*linodego.NodeBalancer, error) {
+	filter := fmt.Sprintf(`{"label": "%v"}`, label)
+	lbs, err := l.client.ListNodeBalancers(ctx, &linodego.ListOptions{Filter: filter})
+	if err != nil {
+		return nil, err
+	}
+	if len(lbs) == 0 {
+		return nil, lbNotFoundError{serviceNn: getServiceNn(service)}
+	}
+	klog.V(2).Infof("found NodeBalancer (%d) for service (%s) via label (%s)", lbs[0].ID, getServiceNn(service), label)
+	return &lbs[0], nil
+}
+
+// getNodeBalancerByIP looks up a NodeBalancer by its IPv4 or IPv6 address.
+func (l *loadbalancers) getNodeBalancerByIP(ctx context.Context, service *v1.Service, ip string) (*linodego.NodeBalancer, error) {
+	field := "ipv4"
+	if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() == nil {
+		field = "ipv6"
+	}
+	filter := fmt.Sprintf(`{"%s": "%v"}`, field, ip)
+	lbs, err := l.client.ListNodeBalancers(ctx, &linodego.ListOptions{Filter: filter})
+	if err != nil {
+		return nil, err
+	}
+	if len(lbs) == 0 {
+		return nil, lbNotFoundError{serviceNn: getServiceNn(service)}
+	}
+	klog.V(2).Infof("found NodeBalancer (%d) for service (%s) via %s (%s)", lbs[0].ID, getServiceNn(service), field, ip)
 	return &lbs[0], nil
 }
 
@@ -614,21 +1073,41 @@ func (l *loadbalancers) getNodeBalancerByID(ctx context.Context, service *v1.Ser
 func (l *loadbalancers) GetLoadBalancerTags(_ context.Context, clusterName string, service *v1.Service) []string {
 	tags := []string{}
 	if clusterName != "" {
-		tags = append(tags, clusterName)
+		tags = append(tags, clusterName, clusterTag(clusterName))
 	}
 
 	tags = append(tags, Options.NodeBalancerTags...)
 
-	tagStr, ok := service.GetAnnotations()[annotations.AnnLinodeLoadBalancerTags]
-	if ok {
-		return append(tags, strings.Split(tagStr, ",")...)
+	if service.UID != "" {
+		tags = append(tags, serviceUIDTag(service))
+	}
+	if l.shouldPreserveNodeBalancer(service) {
+		tags = append(tags, preserveTag)
 	}
 
-	return tags
+	serviceTags := annotations.GetStringSlice(service, annotations.AnnLinodeLoadBalancerTags)
+	if serviceTags == nil {
+		serviceTags = Options.DefaultNodeBalancerTags
+	}
+	return append(tags, serviceTags...)
 }
 
-func (l *loadbalancers) createNodeBalancer(ctx context.Context, clusterName string, service *v1.Service, configs []*linodego.NodeBalancerConfigCreateOptions) (lb *linodego.NodeBalancer, err error) {
-	connThrottle := getConnectionThrottle(service)
+// clusterTag returns the tag marking a NodeBalancer as created by the CCM of
+// the cluster clusterName.
+func clusterTag(clusterName string) string {
+	return clusterTagPrefix + clusterName
+}
+
+// serviceUIDTag returns the tag marking a NodeBalancer as owned by service.
+func serviceUIDTag(service *v1.Service) string {
+	return serviceUIDTagPrefix + string(service.UID)
+}
+
+func (l *loadbalancers) createNodeBalancer(ctx context.Context, clusterName string, service *v1.Service, configs []*linodego.NodeBalancerConfigCreateOptions, vpc *nodeBalancerVPC) (lb *linodego.NodeBalancer, err error) {
+	connThrottle, err := getConnectionThrottle(service)
+	if err != nil {
+		return nil, l.invalidAnnotation(service, err)
+	}
 
 	label := l.GetLoadBalancerName(ctx, clusterName, service)
 	tags := l.GetLoadBalancerTags(ctx, clusterName, service)
@@ -639,25 +1118,37 @@ func (l *loadbalancers) createNodeBalancer(ctx context.Context, clusterName stri
 		Configs:            configs,
 		Tags:               tags,
 	}
+	if vpc != nil {
+		createOpts.VPCs = []linodego.NodeBalancerVPCOptions{{SubnetID: vpc.subnetID}}
+	}
 
-	fwid, ok := service.GetAnnotations()[annotations.AnnLinodeCloudFirewallID]
-	if ok {
-		firewallID, err := strconv.Atoi(fwid)
-		if err != nil {
-			return nil, err
-		}
+	firewallID, err := annotations.GetInt(service, annotations.AnnLinodeCloudFirewallID, 0)
+	if err != nil {
+		return nil, l.invalidAnnotation(service, err)
+	}
+	if firewallID != 0 {
 		createOpts.FirewallID = firewallID
 	} else {
 		// There's no firewallID already set, see if we need to create a new fw, look for the acl annotation.
-		_, ok := service.GetAnnotations()[annotations.AnnLinodeCloudFirewallACL]
+		aclService, err := l.withFirewallACL(ctx, service)
+		if err != nil {
+			return nil, err
+		}
+		_, ok := aclService.GetAnnotations()[annotations.AnnLinodeCloudFirewallACL]
 		if ok {
-			fwcreateOpts, err := firewall.CreateFirewallOptsForSvc(label, tags, service)
-			if err != nil {
+			fwClient := firewall.LinodeClient{Client: l.client, Recorder: l.recorder, Feeds: l.ipFeeds}
+			fwcreateOpts, err := fwClient.CreateFirewallOpts(ctx, label, tags, aclService)
+			if errors.Is(err, firewall.ErrIPFeedUnavailable) {
+				l.eventf(service, v1.EventTypeWarning, eventReasonFirewallFailed, "failed to create firewall: %s", err)
 				return nil, err
 			}
+			if err != nil {
+				return nil, l.invalidAnnotation(service, err)
+			}
 
 			fw, err := l.client.CreateFirewall(ctx, *fwcreateOpts)
 			if err != nil {
+				l.eventf(service, v1.EventTypeWarning, eventReasonFirewallFailed, "failed to create firewall: %s", err)
 				return nil, err
 			}
 			createOpts.FirewallID = fw.ID
@@ -665,19 +1156,32 @@ func (l *loadbalancers) createNodeBalancer(ctx context.Context, clusterName stri
 		// no need to deal with firewalls, continue creating nb's
 	}
 
-	return l.client.CreateNodeBalancer(ctx, createOpts)
+	nb, err := l.client.CreateNodeBalancer(ctx, createOpts)
+	if err != nil {
+		return nil, err
+	}
+	if createOpts.FirewallID != 0 {
+		l.eventf(service, v1.EventTypeNormal, eventReasonFirewallAttached, "attached firewall %d to NodeBalancer %d", createOpts.FirewallID, nb.ID)
+	}
+	return nb, nil
 }
 
 //nolint:funlen
 func (l *loadbalancers) buildNodeBalancerConfig(ctx context.Context, service *v1.Service, port int) (linodego.NodeBalancerConfig, error) {
 	portConfig, err := getPortConfig(service, port)
 	if err != nil {
-		return linodego.NodeBalancerConfig{}, err
+		return linodego.NodeBalancerConfig{}, l.invalidAnnotation(service, err)
 	}
 
-	health, err := getHealthCheckType(service)
-	if err != nil {
-		return linodego.NodeBalancerConfig{}, err
+	health := portConfig.Check
+	if health == "" {
+		if health, err = getHealthCheckType(service); err != nil {
+			return linodego.NodeBalancerConfig{}, l.invalidAnnotation(service, err)
+		}
+		// UDP configs cannot run connection checks
+		if portConfig.Protocol == linodego.ProtocolUDP && health == linodego.CheckConnection {
+			health = linodego.CheckNone
+		}
 	}
 
 	config := linodego.NodeBalancerConfig{
@@ -685,10 +1189,17 @@ func (l *loadbalancers) buildNodeBalancerConfig(ctx context.Context, service *v1
 		Protocol:      portConfig.Protocol,
 		ProxyProtocol: portConfig.ProxyProtocol,
 		Check:         health,
+		Algorithm:     portConfig.Algorithm,
+		Stickiness:    portConfig.Stickiness,
+		CipherSuite:   portConfig.CipherSuite,
 	}
+	setDefaultBalancing(service, &config)
 
 	if health == linodego.CheckHTTP || health == linodego.CheckHTTPBody {
-		path := service.GetAnnotations()[annotations.AnnLinodeCheckPath]
+		path := portConfig.CheckPath
+		if path == "" {
+			path = annotations.GetString(service, annotations.AnnLinodeCheckPath, "")
+		}
 		if path == "" {
 			path = "/"
 		}
@@ -696,51 +1207,85 @@ func (l *loadbalancers) buildNodeBalancerConfig(ctx context.Context, service *v1
 	}
 
 	if health == linodego.CheckHTTPBody {
-		body := service.GetAnnotations()[annotations.AnnLinodeCheckBody]
+		body := portConfig.CheckBody
+		if body == "" {
+			body = annotations.GetString(service, annotations.AnnLinodeCheckBody, "")
+		}
 		if body == "" {
-			return config, fmt.Errorf("for health check type http_body need body regex annotation %v", annotations.AnnLinodeCheckBody)
+			return config, l.invalidAnnotation(service, fmt.Errorf("for health check type http_body need body regex annotation %v", annotations.AnnLinodeCheckBody))
 		}
 		config.CheckBody = body
 	}
-	checkInterval := 5
-	if ci, ok := service.GetAnnotations()[annotations.AnnLinodeHealthCheckInterval]; ok {
-		if checkInterval, err = strconv.Atoi(ci); err != nil {
-			return config, err
-		}
+
+	if config.CheckInterval, err = getPortConfigInt(service, portConfig.CheckInterval, annotations.AnnLinodeHealthCheckInterval, Options.DefaultCheckInterval, 2, 3600); err != nil {
+		return config, l.invalidAnnotation(service, err)
+	}
+	if config.CheckTimeout, err = getPortConfigInt(service, portConfig.CheckTimeout, annotations.AnnLinodeHealthCheckTimeout, Options.DefaultCheckTimeout, 1, 30); err != nil {
+		return config, l.invalidAnnotation(service, err)
+	}
+	if config.CheckAttempts, err = getPortConfigInt(service, portConfig.CheckAttempts, annotations.AnnLinodeHealthCheckAttempts, Options.DefaultCheckAttempts, 1, 30); err != nil {
+		return config, l.invalidAnnotation(service, err)
 	}
-	config.CheckInterval = checkInterval
 
-	checkTimeout := 3
-	if ct, ok := service.GetAnnotations()[annotations.AnnLinodeHealthCheckTimeout]; ok {
-		if checkTimeout, err = strconv.Atoi(ct); err != nil {
-			return config, err
+	switch {
+	case portConfig.Protocol == linodego.ProtocolUDP:
+		// passive checks only inspect TCP-based traffic
+		config.CheckPassive = false
+	case portConfig.CheckPassive != nil:
+		config.CheckPassive = *portConfig.CheckPassive
+	default:
+		if config.CheckPassive, err = annotations.GetBool(service, annotations.AnnLinodeHealthCheckPassive, Options.DefaultCheckPassive); err != nil {
+			return config, l.invalidAnnotation(service, err)
 		}
 	}
-	config.CheckTimeout = checkTimeout
 
-	checkAttempts := 2
-	if ca, ok := service.GetAnnotations()[annotations.AnnLinodeHealthCheckAttempts]; ok {
-		if checkAttempts, err = strconv.Atoi(ca); err != nil {
+	if portConfig.Protocol == linodego.ProtocolHTTPS {
+		if err = l.addTLSCert(ctx, service, &config, portConfig); err != nil {
 			return config, err
 		}
 	}
-	config.CheckAttempts = checkAttempts
 
-	checkPassive := true
-	if cp, ok := service.GetAnnotations()[annotations.AnnLinodeHealthCheckPassive]; ok {
-		if checkPassive, err = strconv.ParseBool(cp); err != nil {
-			return config, err
+	return config, nil
+}
+
+// setDefaultBalancing fills in the algorithm and stickiness of config when
+// neither is set by annotation. They are always set explicitly, so that
+// removing an annotation reverts the NodeBalancer config to the defaults.
+//
+// A Service with ClientIP session affinity keeps each client on the same
+// backend: UDP ports use source_ip stickiness, and TCP-based ports, whose
+// stickiness cannot be keyed on the client address, use the source algorithm.
+func setDefaultBalancing(service *v1.Service, config *linodego.NodeBalancerConfig) {
+	clientIP := service.Spec.SessionAffinity == v1.ServiceAffinityClientIP
+	isUDP := config.Protocol == linodego.ProtocolUDP
+
+	if config.Algorithm == "" {
+		config.Algorithm = linodego.AlgorithmRoundRobin
+		if clientIP && !isUDP {
+			config.Algorithm = linodego.AlgorithmSource
 		}
 	}
-	config.CheckPassive = checkPassive
 
-	if portConfig.Protocol == linodego.ProtocolHTTPS {
-		if err = l.addTLSCert(ctx, service, &config, portConfig); err != nil {
-			return config, err
+	if config.Stickiness == "" {
+		switch {
+		case isUDP && clientIP:
+			config.Stickiness = linodego.StickinessSourceIP
+		case isUDP:
+			// UDP configs do not support the table stickiness the API defaults to
+			config.Stickiness = linodego.StickinessSession
+		default:
+			config.Stickiness = linodego.StickinessNone
 		}
 	}
+}
 
-	return config, nil
+// getPortConfigInt returns the per-port value if set, or else the value,
+// between minValue and maxValue, of the Service annotation ann, or else def.
+func getPortConfigInt(service *v1.Service, portValue *int, ann string, def, minValue, maxValue int) (int, error) {
+	if portValue != nil {
+		return *portValue, nil
+	}
+	return annotations.GetIntInRange(service, ann, def, minValue, maxValue)
 }
 
 func (l *loadbalancers) addTLSCert(ctx context.Context, service *v1.Service, nbConfig *linodego.NodeBalancerConfig, config portConfig) error {
@@ -749,40 +1294,183 @@ func (l *loadbalancers) addTLSCert(ctx context.Context, service *v1.Service, nbC
 		return err
 	}
 
-	nbConfig.SSLCert, nbConfig.SSLKey, err = getTLSCertInfo(ctx, l.kubeClient, service.Namespace, config)
+	cert, key, err := getTLSCertInfo(ctx, l.kubeClient, service.Namespace, config)
+	switch {
+	case k8serrors.IsNotFound(err):
+		l.eventf(service, v1.EventTypeWarning, eventReasonTLSSecretMissing, "TLS secret %s for port %d not found", config.TLSSecretName, config.Port)
+		return err
+	case config.TLSSecretName == "":
+		return l.invalidAnnotation(service, err)
+	case err != nil:
+		return err
+	}
+
+	leaf, err := validateTLSCert(cert, key)
 	if err != nil {
+		err = fmt.Errorf("invalid TLS secret %s for port %d: %w", config.TLSSecretName, config.Port, err)
+		l.eventf(service, v1.EventTypeWarning, eventReasonInvalidTLSSecret, "%s", err)
+		return err
+	}
+
+	untilExpiry := time.Until(leaf.NotAfter)
+	tlsCertificateDaysUntilExpiry.WithLabelValues(service.Namespace, service.Name, strconv.Itoa(config.Port)).Set(untilExpiry.Hours() / 24)
+	switch {
+	case untilExpiry <= 0:
+		err = fmt.Errorf("certificate in TLS secret %s for port %d expired on %s", config.TLSSecretName, config.Port, leaf.NotAfter.Format(time.RFC3339))
+		l.eventf(service, v1.EventTypeWarning, eventReasonInvalidTLSSecret, "%s", err)
 		return err
+	case untilExpiry < tlsCertExpiryWarning:
+		klog.Warningf("certificate in TLS secret %s for port %d of service (%s) expires on %s", config.TLSSecretName, config.Port, getServiceNn(service), leaf.NotAfter.Format(time.RFC3339))
+		l.eventf(service, v1.EventTypeWarning, eventReasonTLSCertificateExpiring,
+			"certificate in TLS secret %s for port %d expires on %s", config.TLSSecretName, config.Port, leaf.NotAfter.Format(time.RFC3339))
 	}
+
+	nbConfig.SSLCert, nbConfig.SSLKey = cert, key
+	// the API redacts the certificate, so remember which one was sent
+	nbConfig.SSLFingerprint = getTLSCertFingerprint(nbConfig.SSLCert)
 	return nil
 }
 
+// validateTLSCert checks that cert is a PEM encoded certificate chain ordered
+// from leaf to root, and that key is the private key of its leaf, which the
+// API would otherwise reject with an opaque error. It returns the leaf.
+func validateTLSCert(cert, key string) (*x509.Certificate, error) {
+	var chain []*x509.Certificate
+	for rest := []byte(cert); ; {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		parsed, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate %d in %s: %w", len(chain)+1, v1.TLSCertKey, err)
+		}
+		chain = append(chain, parsed)
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("%s does not contain a PEM encoded certificate", v1.TLSCertKey)
+	}
+
+	if _, err := tls.X509KeyPair([]byte(cert), []byte(key)); err != nil {
+		return nil, fmt.Errorf("%s does not match the certificate in %s: %w", v1.TLSPrivateKeyKey, v1.TLSCertKey, err)
+	}
+
+	for i := 0; i < len(chain)-1; i++ {
+		if err := chain[i].CheckSignatureFrom(chain[i+1]); err != nil {
+			return nil, fmt.Errorf("certificate %d in %s is not signed by the next certificate, the chain must be ordered from leaf to root: %w", i+1, v1.TLSCertKey, err)
+		}
+	}
+	return chain[0], nil
+}
+
+// getTLSCertFingerprint returns the SHA-256 fingerprint of the first
+// certificate in cert.
+func getTLSCertFingerprint(cert string) string {
+	data := []byte(cert)
+	if block, _ := pem.Decode(data); block != nil {
+		data = block.Bytes
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
+
+// getTLSFingerprints returns the certificate fingerprints, by port, recorded on
+// service when its HTTPS ports were last built.
+func getTLSFingerprints(service *v1.Service) map[int]string {
+	fingerprints := make(map[int]string)
+	if raw, ok := service.GetAnnotations()[annotations.AnnLinodeTLSFingerprints]; ok {
+		if err := json.Unmarshal([]byte(raw), &fingerprints); err != nil {
+			klog.Warningf("Ignoring invalid %s annotation on service %s: %s", annotations.AnnLinodeTLSFingerprints, getServiceNn(service), err)
+			return make(map[int]string)
+		}
+	}
+	return fingerprints
+}
+
+// recordTLSFingerprints stores the certificate fingerprints of the HTTPS ports
+// of service in its annotations, so that a renewed certificate can be told
+// apart from the one the NodeBalancer serves. Failing to record them only
+// causes the next update to rebuild the HTTPS configs again.
+func (l *loadbalancers) recordTLSFingerprints(ctx context.Context, service *v1.Service, fingerprints map[int]string) {
+	if reflect.DeepEqual(getTLSFingerprints(service), fingerprints) {
+		return
+	}
+	if err := l.retrieveKubeClient(); err != nil {
+		klog.Warningf("Unable to record TLS fingerprints of service %s: %s", getServiceNn(service), err)
+		return
+	}
+
+	var value any // a null value removes the annotation
+	if len(fingerprints) > 0 {
+		raw, err := json.Marshal(fingerprints)
+		if err != nil {
+			klog.Warningf("Unable to record TLS fingerprints of service %s: %s", getServiceNn(service), err)
+			return
+		}
+		value = string(raw)
+	}
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{
+			"annotations": map[string]any{annotations.AnnLinodeTLSFingerprints: value},
+		},
+	})
+	if err != nil {
+		klog.Warningf("Unable to record TLS fingerprints of service %s: %s", getServiceNn(service), err)
+		return
+	}
+	if _, err = l.kubeClient.CoreV1().Services(service.Namespace).Patch(ctx, service.Name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		klog.Warningf("Unable to record TLS fingerprints of service %s: %s", getServiceNn(service), err)
+	}
+}
+
 // buildLoadBalancerRequest returns a linodego.NodeBalancer
 // requests for service across nodes.
 func (l *loadbalancers) buildLoadBalancerRequest(ctx context.Context, clusterName string, service *v1.Service, nodes []*v1.Node) (*linodego.NodeBalancer, error) {
 	if len(nodes) == 0 {
+		l.eventf(service, v1.EventTypeWarning, eventReasonNoNodesAvailable, "no nodes available to back a NodeBalancer")
+		return nil, fmt.Errorf("%w: cluster %s, service %s", errNoNodesAvailable, clusterName, getServiceNn(service))
+	}
+	if err := validateServicePorts(service); err != nil {
+		return nil, err
+	}
+	nodes = l.getLocalTrafficNodes(service, nodes)
+	vpc, err := l.getNodeBalancerVPC(ctx, service, nodes, nil)
+	if err != nil {
+		return nil, err
+	}
+	if nodes = vpc.filterNodes(nodes); len(nodes) == 0 {
+		l.eventf(service, v1.EventTypeWarning, eventReasonNoNodesAvailable, "no nodes in subnet %d to back a NodeBalancer", vpc.subnetID)
 		return nil, fmt.Errorf("%w: cluster %s, service %s", errNoNodesAvailable, clusterName, getServiceNn(service))
 	}
 	ports := service.Spec.Ports
 	configs := make([]*linodego.NodeBalancerConfigCreateOptions, 0, len(ports))
 
-	for _, port := range ports {
-		if port.Protocol == v1.ProtocolUDP {
-			return nil, fmt.Errorf("error creating NodeBalancer Config: ports with the UDP protocol are not supported")
-		}
+	fingerprints := make(map[int]string)
 
+	for _, port := range ports {
 		config, err := l.buildNodeBalancerConfig(ctx, service, int(port.Port))
 		if err != nil {
 			return nil, err
 		}
+		if config.SSLFingerprint != "" {
+			fingerprints[config.Port] = config.SSLFingerprint
+		}
 		createOpt := config.GetCreateOptions()
 
 		for _, n := range nodes {
-			createOpt.Nodes = append(createOpt.Nodes, l.buildNodeBalancerNodeConfigRebuildOptions(n, port.NodePort).NodeBalancerNodeCreateOptions)
+			createOpt.Nodes = append(createOpt.Nodes, l.buildNodeBalancerNodeConfigRebuildOptions(n, port.NodePort, vpc).NodeBalancerNodeCreateOptions)
 		}
 
 		configs = append(configs, &createOpt)
 	}
-	return l.createNodeBalancer(ctx, clusterName, service, configs)
+	nb, err := l.createNodeBalancer(ctx, clusterName, service, configs, vpc)
+	if err != nil {
+		return nil, err
+	}
+	l.recordTLSFingerprints(ctx, service, fingerprints)
+	return nb, nil
 }
 
 func coerceString(s string, minLen, maxLen int, padding string) string {
@@ -797,19 +1485,192 @@ func coerceString(s string, minLen, maxLen int, padding string) string {
 	return s
 }
 
-func (l *loadbalancers) buildNodeBalancerNodeConfigRebuildOptions(node *v1.Node, nodePort int32) linodego.NodeBalancerConfigRebuildNodeOptions {
+func (l *loadbalancers) buildNodeBalancerNodeConfigRebuildOptions(node *v1.Node, nodePort int32, vpc *nodeBalancerVPC) linodego.NodeBalancerConfigRebuildNodeOptions {
+	address := getNodePrivateIP(node)
+	subnetID := 0
+	if vpc != nil {
+		address, _ = vpc.nodeAddress(node)
+		subnetID = vpc.subnetID
+	}
 	return linodego.NodeBalancerConfigRebuildNodeOptions{
 		NodeBalancerNodeCreateOptions: linodego.NodeBalancerNodeCreateOptions{
-			Address: fmt.Sprintf("%v:%v", getNodePrivateIP(node), nodePort),
+			Address: fmt.Sprintf("%v:%v", address, nodePort),
 			// NodeBalancer backends must be 3-32 chars in length
 			// If < 3 chars, pad node name with "node-" prefix
-			Label:  coerceString(node.Name, 3, 32, "node-"),
-			Mode:   "accept",
-			Weight: 100,
+			Label:    coerceString(node.Name, 3, 32, "node-"),
+			Mode:     getNodeBackendMode(node),
+			Weight:   getNodeBackendWeight(node),
+			SubnetID: subnetID,
 		},
 	}
 }
 
+// nodeBalancerConfigChanged reports whether the live config differs from the
+// desired one. As the API redacts the certificate of HTTPS configs, the
+// fingerprint of the desired certificate is compared with the fingerprint
+// recorded when the config was last built instead.
+func nodeBalancerConfigChanged(current, desired *linodego.NodeBalancerConfig, recordedFingerprint string) bool {
+	if desired.Protocol == linodego.ProtocolHTTPS && (desired.SSLFingerprint == "" || desired.SSLFingerprint != recordedFingerprint) {
+		return true
+	}
+	if current.Protocol != desired.Protocol ||
+		current.ProxyProtocol != desired.ProxyProtocol ||
+		current.Algorithm != desired.Algorithm ||
+		current.Stickiness != desired.Stickiness ||
+		current.Check != desired.Check ||
+		current.CheckInterval != desired.CheckInterval ||
+		current.CheckTimeout != desired.CheckTimeout ||
+		current.CheckAttempts != desired.CheckAttempts ||
+		current.CheckPassive != desired.CheckPassive {
+		return true
+	}
+	// the path and body are only used by HTTP checks
+	if desired.Check == linodego.CheckHTTP || desired.Check == linodego.CheckHTTPBody {
+		if current.CheckPath != desired.CheckPath || current.CheckBody != desired.CheckBody {
+			return true
+		}
+	}
+	return desired.CipherSuite != "" && current.CipherSuite != desired.CipherSuite
+}
+
+// nodeBalancerNodesChanged reports whether the live backends of a config
+// differ from the desired ones.
+func nodeBalancerNodesChanged(current []linodego.NodeBalancerNode, desired []linodego.NodeBalancerConfigRebuildNodeOptions) bool {
+	if len(current) != len(desired) {
+		return true
+	}
+	currentByAddress := make(map[string]linodego.NodeBalancerNode, len(current))
+	for _, node := range current {
+		currentByAddress[node.Address] = node
+	}
+	for _, node := range desired {
+		live, ok := currentByAddress[node.Address]
+		if !ok || live.Label != node.Label || live.Weight != node.Weight || live.Mode != node.Mode {
+			return true
+		}
+	}
+	return false
+}
+
+// getLocalTrafficNodes returns the nodes hosting ready endpoints of a Service
+// with the Local external traffic policy, as other nodes drop its traffic.
+// All nodes are returned for other Services, before the EndpointSlices are
+// known, and when no node has ready endpoints, so that the NodeBalancer
+// keeps its backends until the pods come back.
+func (l *loadbalancers) getLocalTrafficNodes(service *v1.Service, nodes []*v1.Node) []*v1.Node {
+	if service.Spec.ExternalTrafficPolicy != v1.ServiceExternalTrafficPolicyLocal || l.endpointSlices == nil {
+		return nodes
+	}
+
+	selector := labels.SelectorFromSet(labels.Set{discoveryv1.LabelServiceName: service.Name})
+	endpointSlices, err := l.endpointSlices.EndpointSlices(service.Namespace).List(selector)
+	if err != nil {
+		klog.Warningf("Unable to list EndpointSlices of service %s, using all nodes: %s", getServiceNn(service), err)
+		return nodes
+	}
+
+	ready := make(map[string]bool)
+	for _, slice := range endpointSlices {
+		for _, endpoint := range slice.Endpoints {
+			// a nil ready condition means the endpoint is ready
+			if endpoint.NodeName != nil && (endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready) {
+				ready[*endpoint.NodeName] = true
+			}
+		}
+	}
+
+	local := make([]*v1.Node, 0, len(ready))
+	for _, node := range nodes {
+		if ready[node.Name] {
+			local = append(local, node)
+		}
+	}
+	if len(local) == 0 {
+		klog.Warningf("No node hosts ready endpoints of service %s, using all nodes", getServiceNn(service))
+		return nodes
+	}
+	return local
+}
+
+// getNodeBackendWeight returns the weight of node in NodeBalancers, falling
+// back to the default when the node annotation is invalid.
+func getNodeBackendWeight(node *v1.Node) int {
+	weight, err := annotations.GetIntInRange(node, annotations.AnnLinodeNodeBalancerBackendWeight, defaultNodeBackendWeight, 1, 255)
+	if err != nil {
+		klog.Warningf("Node %s has an invalid annotation: %s", node.Name, err)
+		countInvalidAnnotation(err)
+	}
+	return weight
+}
+
+// getNodeBackendMode returns the mode of node in NodeBalancers. Cordoned nodes
+// and nodes excluded from load balancers are drained, so that they stop
+// receiving new connections while existing ones finish.
+func getNodeBackendMode(node *v1.Node) linodego.NodeMode {
+	if isNodeDraining(node) {
+		return linodego.ModeDrain
+	}
+	mode, err := annotations.GetEnum(node, annotations.AnnLinodeNodeBalancerBackendMode, linodego.ModeAccept,
+		linodego.ModeAccept, linodego.ModeReject, linodego.ModeDrain, linodego.ModeBackup)
+	if err != nil {
+		klog.Warningf("Node %s has an invalid annotation: %s", node.Name, err)
+		countInvalidAnnotation(err)
+	}
+	return mode
+}
+
+func isNodeDraining(node *v1.Node) bool {
+	_, excluded := node.GetLabels()[v1.LabelNodeExcludeBalancers]
+	return node.Spec.Unschedulable || excluded
+}
+
+// getLoadBalancerNodes returns the nodes the cloud-provider service controller
+// would pass to UpdateLoadBalancer, for updates triggered by the CCM itself.
+func getLoadBalancerNodes(lister corelisters.NodeLister) ([]*v1.Node, error) {
+	allNodes, err := lister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]*v1.Node, 0, len(allNodes))
+	for _, node := range allNodes {
+		if _, excluded := node.Labels[v1.LabelNodeExcludeBalancers]; excluded || node.DeletionTimestamp != nil {
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// getDrainingNodes returns the cordoned nodes and the nodes excluded from load
+// balancers. The service controller stops passing excluded nodes to the
+// provider, so they are looked up to keep draining their existing backends.
+// Nodes both cordoned and excluded are returned once.
+func (l *loadbalancers) getDrainingNodes(ctx context.Context) ([]*v1.Node, error) {
+	if err := l.retrieveKubeClient(); err != nil {
+		return nil, err
+	}
+
+	var draining []*v1.Node
+	seen := make(map[string]bool)
+	for _, opts := range []metav1.ListOptions{
+		{FieldSelector: "spec.unschedulable=true"},
+		{LabelSelector: v1.LabelNodeExcludeBalancers},
+	} {
+		nodes, err := l.kubeClient.CoreV1().Nodes().List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		for i := range nodes.Items {
+			node := &nodes.Items[i]
+			if isNodeDraining(node) && !seen[node.Name] {
+				seen[node.Name] = true
+				draining = append(draining, node)
+			}
+		}
+	}
+	return draining, nil
+}
+
 func (l *loadbalancers) retrieveKubeClient() error {
 	if l.kubeClient != nil {
 		return nil
@@ -847,11 +1708,15 @@ func getPortConfig(service *v1.Service, port int) (portConfig, error) {
 	if err != nil {
 		return portConfig, err
 	}
+	isUDP := getServicePortProtocol(service, port) == v1.ProtocolUDP
+
 	protocol := portConfigAnnotation.Protocol
 	if protocol == "" {
-		protocol = "tcp"
-		if p, ok := service.GetAnnotations()[annotations.AnnLinodeDefaultProtocol]; ok {
-			protocol = p
+		if isUDP {
+			// the default protocol annotation only covers TCP-based ports
+			protocol = string(linodego.ProtocolUDP)
+		} else {
+			protocol = annotations.GetString(service, annotations.AnnLinodeDefaultProtocol, Options.DefaultProtocol)
 		}
 	}
 	protocol = strings.ToLower(protocol)
@@ -859,18 +1724,21 @@ func getPortConfig(service *v1.Service, port int) (portConfig, error) {
 	proxyProtocol := portConfigAnnotation.ProxyProtocol
 	if proxyProtocol == "" {
 		proxyProtocol = string(linodego.ProxyProtocolNone)
-		for _, ann := range []string{annotations.AnnLinodeDefaultProxyProtocol, annLinodeProxyProtocolDeprecated} {
-			if pp, ok := service.GetAnnotations()[ann]; ok {
-				proxyProtocol = pp
-				break
-			}
+		if !isUDP {
+			proxyProtocol = Options.DefaultProxyProtocol
+			proxyProtocol = annotations.GetString(service, annotations.AnnLinodeDefaultProxyProtocol,
+				annotations.GetString(service, annLinodeProxyProtocolDeprecated, proxyProtocol))
 		}
 	}
 
-	if protocol != "tcp" && protocol != "http" && protocol != "https" {
+	if protocol != "tcp" && protocol != "http" && protocol != "https" && protocol != string(linodego.ProtocolUDP) {
 		return portConfig, fmt.Errorf("invalid protocol: %q specified", protocol)
 	}
 
+	if isUDP != (protocol == string(linodego.ProtocolUDP)) {
+		return portConfig, fmt.Errorf("protocol %q is not valid for port %d with Service protocol %s", protocol, port, getServicePortProtocol(service, port))
+	}
+
 	switch proxyProtocol {
 	case string(linodego.ProxyProtocolNone), string(linodego.ProxyProtocolV1), string(linodego.ProxyProtocolV2):
 		break
@@ -878,40 +1746,134 @@ func getPortConfig(service *v1.Service, port int) (portConfig, error) {
 		return portConfig, fmt.Errorf("invalid NodeBalancer proxy protocol value '%s'", proxyProtocol)
 	}
 
+	if isUDP && proxyProtocol != string(linodego.ProxyProtocolNone) {
+		return portConfig, fmt.Errorf("proxy protocol is not supported for UDP port %d", port)
+	}
+
 	portConfig.Port = port
 	portConfig.Protocol = linodego.ConfigProtocol(protocol)
 	portConfig.ProxyProtocol = linodego.ConfigProxyProtocol(proxyProtocol)
 	portConfig.TLSSecretName = portConfigAnnotation.TLSSecretName
-
-	return portConfig, nil
+	portConfig.CheckPath = portConfigAnnotation.CheckPath
+	portConfig.CheckBody = portConfigAnnotation.CheckBody
+	portConfig.CheckInterval = portConfigAnnotation.CheckInterval
+	portConfig.CheckTimeout = portConfigAnnotation.CheckTimeout
+	portConfig.CheckAttempts = portConfigAnnotation.CheckAttempts
+	portConfig.CheckPassive = portConfigAnnotation.CheckPassive
+
+	return withPortConfigTuning(service, portConfig, portConfigAnnotation)
 }
 
-func getHealthCheckType(service *v1.Service) (linodego.ConfigCheck, error) {
-	hType, ok := service.GetAnnotations()[annotations.AnnLinodeHealthCheckType]
-	if !ok {
-		return linodego.CheckConnection, nil
+// withPortConfigTuning validates the health check type, algorithm, stickiness
+// and cipher suite set in the port annotation against the port protocol, and
+// returns config with them set. The algorithm and stickiness fall back to the
+// Service annotations.
+func withPortConfigTuning(service *v1.Service, config portConfig, annotation portConfigAnnotation) (portConfig, error) {
+	isUDP := config.Protocol == linodego.ProtocolUDP
+
+	switch check := linodego.ConfigCheck(annotation.Check); check {
+	case "":
+	case linodego.CheckNone, linodego.CheckHTTP, linodego.CheckHTTPBody:
+		config.Check = check
+	case linodego.CheckConnection:
+		if isUDP {
+			return portConfig{}, fmt.Errorf("health check type %q is not supported for UDP port %d", check, config.Port)
+		}
+		config.Check = check
+	default:
+		return portConfig{}, fmt.Errorf("invalid health check type: %q specified for port %d", check, config.Port)
 	}
-	if hType != "none" && hType != "connection" && hType != "http" && hType != "http_body" {
-		return "", fmt.Errorf("invalid health check type: %q specified in annotation: %q", hType, annotations.AnnLinodeHealthCheckType)
+
+	if isUDP && config.CheckPassive != nil && *config.CheckPassive {
+		return portConfig{}, fmt.Errorf("passive health checks are not supported for UDP port %d", config.Port)
 	}
-	return linodego.ConfigCheck(hType), nil
-}
 
-func getPortConfigAnnotation(service *v1.Service, port int) (portConfigAnnotation, error) {
-	annotation := portConfigAnnotation{}
-	annotationKey := annotations.AnnLinodePortConfigPrefix + strconv.Itoa(port)
-	annotationJSON, ok := service.GetAnnotations()[annotationKey]
+	algorithm := linodego.ConfigAlgorithm(annotation.Algorithm)
+	if algorithm == "" {
+		algorithm = linodego.ConfigAlgorithm(annotations.GetString(service, annotations.AnnLinodeLoadBalancerAlgorithm, ""))
+	}
+	algorithms := []linodego.ConfigAlgorithm{linodego.AlgorithmRoundRobin, linodego.AlgorithmLeastConn, linodego.AlgorithmSource}
+	if isUDP {
+		algorithms = []linodego.ConfigAlgorithm{linodego.AlgorithmRoundRobin, linodego.AlgorithmLeastConn, linodego.AlgorithmRingHash}
+	}
+	switch {
+	case algorithm == "":
+	case isUDP && !slices.Contains(algorithms, algorithm):
+		return portConfig{}, fmt.Errorf("algorithm %q is not supported for UDP port %d, use roundrobin, leastconn or ring_hash", algorithm, config.Port)
+	case !slices.Contains(algorithms, algorithm):
+		return portConfig{}, fmt.Errorf("invalid algorithm: %q specified for port %d", algorithm, config.Port)
+	}
+	config.Algorithm = algorithm
 
-	if !ok {
-		return annotation, nil
+	stickiness := linodego.ConfigStickiness(annotation.Stickiness)
+	if stickiness == "" {
+		stickiness = linodego.ConfigStickiness(annotations.GetString(service, annotations.AnnLinodeLoadBalancerStickiness, ""))
 	}
+	isHTTP := config.Protocol == linodego.ProtocolHTTP || config.Protocol == linodego.ProtocolHTTPS
+	switch {
+	case stickiness == "":
+	case isUDP && !slices.Contains([]linodego.ConfigStickiness{linodego.StickinessNone, linodego.StickinessSession, linodego.StickinessSourceIP}, stickiness):
+		return portConfig{}, fmt.Errorf("stickiness %q is not supported for UDP port %d, use none, session or source_ip", stickiness, config.Port)
+	case stickiness == linodego.StickinessHTTPCookie && !isHTTP:
+		return portConfig{}, fmt.Errorf("stickiness %q requires an http or https port, port %d is %s", stickiness, config.Port, config.Protocol)
+	case !isUDP && !slices.Contains([]linodego.ConfigStickiness{linodego.StickinessNone, linodego.StickinessTable, linodego.StickinessHTTPCookie}, stickiness):
+		return portConfig{}, fmt.Errorf("invalid stickiness: %q specified for %s port %d", stickiness, config.Protocol, config.Port)
+	}
+	config.Stickiness = stickiness
 
-	err := json.Unmarshal([]byte(annotationJSON), &annotation)
-	if err != nil {
-		return annotation, err
+	switch cipher := linodego.ConfigCipher(annotation.CipherSuite); cipher {
+	case "":
+	case linodego.CipherRecommended, linodego.CipherLegacy:
+		if config.Protocol != linodego.ProtocolHTTPS {
+			return portConfig{}, fmt.Errorf("cipher suite is only supported for https ports, port %d is %s", config.Port, config.Protocol)
+		}
+		config.CipherSuite = cipher
+	default:
+		return portConfig{}, fmt.Errorf("invalid cipher suite: %q specified for port %d", cipher, config.Port)
+	}
+	return config, nil
+}
+
+// getServicePortProtocol returns the Kubernetes protocol of the Service port
+// matching port, defaulting to TCP when the port is not declared.
+func getServicePortProtocol(service *v1.Service, port int) v1.Protocol {
+	for _, sp := range service.Spec.Ports {
+		if int(sp.Port) == port && sp.Protocol != "" {
+			return sp.Protocol
+		}
 	}
+	return v1.ProtocolTCP
+}
 
-	return annotation, nil
+// validateServicePorts ensures the Service ports can be mapped onto NodeBalancer
+// configs, which are keyed by port number alone.
+func validateServicePorts(service *v1.Service) error {
+	seen := make(map[int32]v1.Protocol, len(service.Spec.Ports))
+	for _, port := range service.Spec.Ports {
+		protocol := port.Protocol
+		if protocol == "" {
+			protocol = v1.ProtocolTCP
+		}
+		if protocol == v1.ProtocolSCTP {
+			return fmt.Errorf("port %d: the SCTP protocol is not supported by NodeBalancers", port.Port)
+		}
+		if other, ok := seen[port.Port]; ok && other != protocol {
+			return fmt.Errorf("port %d is declared for both %s and %s, which a NodeBalancer cannot serve", port.Port, other, protocol)
+		}
+		seen[port.Port] = protocol
+	}
+	return nil
+}
+
+func getHealthCheckType(service *v1.Service) (linodego.ConfigCheck, error) {
+	return annotations.GetEnum(service, annotations.AnnLinodeHealthCheckType, linodego.CheckConnection,
+		linodego.CheckNone, linodego.CheckConnection, linodego.CheckHTTP, linodego.CheckHTTPBody)
+}
+
+func getPortConfigAnnotation(service *v1.Service, port int) (portConfigAnnotation, error) {
+	annotation := portConfigAnnotation{}
+	_, err := annotations.GetJSON(service, annotations.AnnLinodePortConfigPrefix+strconv.Itoa(port), &annotation)
+	return annotation, err
 }
 
 // getNodePrivateIP should provide the Linode Private IP the NodeBalance
@@ -932,6 +1894,161 @@ func getNodePrivateIP(node *v1.Node) string {
 	return ""
 }
 
+// nodeBalancerVPC is the VPC subnet a NodeBalancer is attached to, through
+// which it reaches its backends.
+type nodeBalancerVPC struct {
+	subnetID int
+	// addresses maps the Linode ID of each instance to its IP in the subnet
+	addresses map[int]string
+}
+
+// usesVPCBackends reports whether a NodeBalancer created for service should
+// be attached to a VPC.
+func usesVPCBackends(service *v1.Service) bool {
+	if Options.EnableNodeBalancerVPCBackends {
+		return true
+	}
+	serviceAnnotations := service.GetAnnotations()
+	_, hasVPC := serviceAnnotations[annotations.AnnLinodeNodeBalancerBackendVPCName]
+	_, hasSubnet := serviceAnnotations[annotations.AnnLinodeNodeBalancerBackendSubnetName]
+	return hasVPC || hasSubnet
+}
+
+// getNodeBalancerVPC returns the VPC subnet the NodeBalancer of service is
+// attached to, or should be attached to when nb is nil. It returns nil when
+// the backends are addressed by their private IPs. A NodeBalancer can only be
+// attached to a VPC when it is created, so NodeBalancers created without one
+// keep their private IP backends.
+//
+// The VPC IPs of the nodes are those cached for the route controller, so the
+// VPC must be one of the --vpc-names, and the subnet one of the --subnet-names
+// if set.
+func (l *loadbalancers) getNodeBalancerVPC(ctx context.Context, service *v1.Service, nodes []*v1.Node, nb *linodego.NodeBalancer) (*nodeBalancerVPC, error) {
+	if !usesVPCBackends(service) {
+		return nil, nil
+	}
+
+	vpcNames := splitNames(Options.VPCNames)
+	vpcName := annotations.GetString(service, annotations.AnnLinodeNodeBalancerBackendVPCName, "")
+	if vpcName == "" && len(vpcNames) > 0 {
+		vpcName = vpcNames[0]
+	}
+	if vpcName == "" {
+		return nil, l.invalidAnnotation(service, fmt.Errorf("no VPC to attach the NodeBalancer to: set the %s annotation or the --vpc-names flag", annotations.AnnLinodeNodeBalancerBackendVPCName))
+	}
+	if !slices.Contains(vpcNames, vpcName) {
+		return nil, l.invalidAnnotation(service, fmt.Errorf("VPC %s of the %s annotation must be one of the --vpc-names %v", vpcName, annotations.AnnLinodeNodeBalancerBackendVPCName, vpcNames))
+	}
+	vpcID, err := GetVPCID(ctx, l.client, vpcName)
+	if err != nil {
+		return nil, err
+	}
+
+	subnetID := 0
+	if nb != nil {
+		vpcConfigs, err := l.client.ListNodeBalancerVPCConfigs(ctx, nb.ID, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(vpcConfigs) == 0 {
+			klog.Warningf("NodeBalancer (%d) of service (%s) is not attached to a VPC, keeping private IP backends", nb.ID, getServiceNn(service))
+			return nil, nil
+		}
+		subnetID = vpcConfigs[0].SubnetID
+	} else {
+		subnetNames := splitNames(Options.SubnetNames)
+		subnetName := annotations.GetString(service, annotations.AnnLinodeNodeBalancerBackendSubnetName, "")
+		if subnetName == "" && len(subnetNames) > 0 {
+			subnetName = subnetNames[0]
+		}
+		if subnetName != "" {
+			if len(subnetNames) > 0 && !slices.Contains(subnetNames, subnetName) {
+				return nil, l.invalidAnnotation(service, fmt.Errorf("subnet %s of the %s annotation must be one of the --subnet-names %v", subnetName, annotations.AnnLinodeNodeBalancerBackendSubnetName, subnetNames))
+			}
+			if subnetID, err = GetSubnetID(ctx, l.client, vpcID, subnetName); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if l.routeCache == nil {
+		return nil, fmt.Errorf("no VPC IPs cache to find the backends of VPC %s in", vpcName)
+	}
+	vpcIPs := l.routeCache.vpcIPs(ctx, l.client, vpcID)
+	if len(vpcIPs) == 0 {
+		// the cache failed to refresh, rather than all nodes leaving the VPC
+		return nil, fmt.Errorf("no IPs of VPC %s are cached yet", vpcName)
+	}
+
+	// without a subnet name, use the subnet all of the backends are in
+	if subnetID == 0 {
+		nodeIDs := make(map[int]bool, len(nodes))
+		for _, node := range nodes {
+			if id, err := parseProviderID(node.Spec.ProviderID); err == nil {
+				nodeIDs[id] = true
+			}
+		}
+		backendSubnetIDs := make(map[int]bool)
+		for _, ip := range vpcIPs {
+			if ip.Address != nil && nodeIDs[ip.LinodeID] {
+				backendSubnetIDs[ip.SubnetID] = true
+			}
+		}
+		if len(backendSubnetIDs) != 1 {
+			return nil, l.invalidAnnotation(service, fmt.Errorf("nodes are in %d subnets of VPC %s: set the %s annotation or the --subnet-names flag", len(backendSubnetIDs), vpcName, annotations.AnnLinodeNodeBalancerBackendSubnetName))
+		}
+		for id := range backendSubnetIDs {
+			subnetID = id
+		}
+	}
+
+	vpc := &nodeBalancerVPC{subnetID: subnetID, addresses: make(map[int]string)}
+	for _, ip := range vpcIPs {
+		if ip.Address != nil && ip.SubnetID == subnetID {
+			vpc.addresses[ip.LinodeID] = *ip.Address
+		}
+	}
+	return vpc, nil
+}
+
+// splitNames returns the names of a comma separated flag.
+func splitNames(names string) []string {
+	var split []string
+	for _, name := range strings.Split(names, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			split = append(split, name)
+		}
+	}
+	return split
+}
+
+// nodeAddress returns the IP of node in the subnet.
+func (v *nodeBalancerVPC) nodeAddress(node *v1.Node) (string, bool) {
+	id, err := parseProviderID(node.Spec.ProviderID)
+	if err != nil {
+		return "", false
+	}
+	address, ok := v.addresses[id]
+	return address, ok
+}
+
+// filterNodes returns the nodes that have an IP in the subnet, as the
+// NodeBalancer cannot reach the others. All nodes are returned when v is nil.
+func (v *nodeBalancerVPC) filterNodes(nodes []*v1.Node) []*v1.Node {
+	if v == nil {
+		return nodes
+	}
+	filtered := make([]*v1.Node, 0, len(nodes))
+	for _, node := range nodes {
+		if _, ok := v.nodeAddress(node); !ok {
+			klog.Warningf("Node %s has no IP in subnet %d, skipping it as a NodeBalancer backend", node.Name, v.subnetID)
+			continue
+		}
+		filtered = append(filtered, node)
+	}
+	return filtered
+}
+
 func getTLSCertInfo(ctx context.Context, kubeClient kubernetes.Interface, namespace string, config portConfig) (string, string, error) {
 	if config.TLSSecretName == "" {
 		return "", "", fmt.Errorf("TLS secret name for port %v is not specified", config.Port)
@@ -952,40 +2069,59 @@ func getTLSCertInfo(ctx context.Context, kubeClient kubernetes.Interface, namesp
 	return cert, key, nil
 }
 
-func getConnectionThrottle(service *v1.Service) int {
-	connThrottle := 0 // disable throttle if nothing is specified
-
-	if connThrottleString := service.GetAnnotations()[annotations.AnnLinodeThrottle]; connThrottleString != "" {
-		parsed, err := strconv.Atoi(connThrottleString)
-		if err == nil {
-			if parsed < 0 {
-				parsed = 0
-			}
-
-			if parsed > 20 {
-				parsed = 20
-			}
-			connThrottle = parsed
-		}
-	}
-
-	return connThrottle
+// getConnectionThrottle returns the client connection throttle of the
+// NodeBalancer of service, which defaults to --default-throttle.
+func getConnectionThrottle(service *v1.Service) (int, error) {
+	return annotations.GetIntInRange(service, annotations.AnnLinodeThrottle, Options.DefaultThrottle, 0, maxConnThrottle)
 }
 
 func makeLoadBalancerStatus(service *v1.Service, nb *linodego.NodeBalancer) *v1.LoadBalancerStatus {
 	ingress := v1.LoadBalancerIngress{
 		Hostname: *nb.Hostname,
 	}
-	if !getServiceBoolAnnotation(service, annotations.AnnLinodeHostnameOnlyIngress) {
-		if val := envBoolOptions("LINODE_HOSTNAME_ONLY_INGRESS"); val {
-			klog.Infof("LINODE_HOSTNAME_ONLY_INGRESS:  (%v)", val)
-		} else {
-			ingress.IP = *nb.IPv4
+	if getServiceBoolAnnotation(service, annotations.AnnLinodeHostnameOnlyIngress) {
+		return &v1.LoadBalancerStatus{Ingress: []v1.LoadBalancerIngress{ingress}}
+	}
+	if val := envBoolOptions("LINODE_HOSTNAME_ONLY_INGRESS"); val {
+		klog.Infof("LINODE_HOSTNAME_ONLY_INGRESS:  (%v)", val)
+		return &v1.LoadBalancerStatus{Ingress: []v1.LoadBalancerIngress{ingress}}
+	}
+
+	// publish an address for each of the Service's IP families, in order, with
+	// the hostname on the first one
+	status := &v1.LoadBalancerStatus{}
+	for _, family := range getServiceIPFamilies(service) {
+		var ip *string
+		switch family {
+		case v1.IPv4Protocol:
+			ip = nb.IPv4
+		case v1.IPv6Protocol:
+			ip = nb.IPv6
 		}
+		if ip == nil || *ip == "" {
+			continue
+		}
+		ingress.IP = strings.TrimSuffix(*ip, "/128")
+		status.Ingress = append(status.Ingress, ingress)
+		ingress = v1.LoadBalancerIngress{}
 	}
-	return &v1.LoadBalancerStatus{
-		Ingress: []v1.LoadBalancerIngress{ingress},
+	if len(status.Ingress) == 0 {
+		status.Ingress = []v1.LoadBalancerIngress{ingress}
 	}
+	return status
+}
+
+// getServiceIPFamilies returns the IP families of service. Services created
+// before the API server assigned them, or without a cluster IP, are IPv4.
+func getServiceIPFamilies(service *v1.Service) []v1.IPFamily {
+	families := service.Spec.IPFamilies
+	if len(families) == 0 {
+		return []v1.IPFamily{v1.IPv4Protocol}
+	}
+	if service.Spec.IPFamilyPolicy != nil && *service.Spec.IPFamilyPolicy == v1.IPFamilyPolicySingleStack {
+		return families[:1]
+	}
+	return families
 }
 
 // Checks for a truth value in an environment variable
@@ -1002,11 +2138,13 @@ func getServiceNn(service *v1.Service) string {
 	return fmt.Sprintf("%s/%s", service.Namespace, service.Name)
 }
 
+// getServiceBoolAnnotation returns the boolean value of annotation name of
+// service, which is false when the annotation is not set or invalid.
 func getServiceBoolAnnotation(service *v1.Service, name string) bool {
-	value, ok := service.GetAnnotations()[name]
-	if !ok {
-		return false
+	value, err := annotations.GetBool(service, name, false)
+	if err != nil {
+		klog.Warningf("Service %s has an invalid annotation: %s", getServiceNn(service), err)
+		countInvalidAnnotation(err)
 	}
-	boolValue, err := strconv.ParseBool(value)
-	return err == nil && boolValue
+	return value
 }
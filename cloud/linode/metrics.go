@@ -4,14 +4,36 @@ import (
 	"sync"
 
 	"github.com/linode/linode-cloud-controller-manager/cloud/linode/client"
+	"github.com/prometheus/client_golang/prometheus"
 
 	"k8s.io/component-base/metrics/legacyregistry"
 )
 
 var registerOnce sync.Once
 
+var nodeBalancerConfigRebuildsSkipped = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Name: "ccm_linode_nodebalancer_config_rebuilds_skipped_total",
+		Help: "number of NodeBalancer config rebuilds skipped because the config was up to date",
+	})
+
+var tlsCertificateDaysUntilExpiry = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "ccm_linode_nodebalancer_tls_certificate_days_until_expiry",
+		Help: "days until the certificate of an HTTPS NodeBalancer port expires, negative once expired",
+	}, []string{"namespace", "service", "port"})
+
+var invalidAnnotations = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "ccm_linode_invalid_annotations_total",
+		Help: "number of times an annotation of a Service or Node could not be parsed, by annotation",
+	}, []string{"annotation"})
+
 func registerMetrics() {
 	registerOnce.Do(func() {
 		legacyregistry.RawMustRegister(client.ClientMethodCounterVec)
+		legacyregistry.RawMustRegister(nodeBalancerConfigRebuildsSkipped)
+		legacyregistry.RawMustRegister(tlsCertificateDaysUntilExpiry)
+		legacyregistry.RawMustRegister(invalidAnnotations)
 	})
 }
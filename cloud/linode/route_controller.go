@@ -56,6 +56,24 @@ func (rc *routeCache) refreshRoutes(ctx context.Context, client client.Client) {
 	rc.lastUpdate = time.Now()
 }
 
+// vpcIPs returns the cached IPs of the VPC vpcID, refreshing the cache if it
+// has expired.
+func (rc *routeCache) vpcIPs(ctx context.Context, client client.Client, vpcID int) []linodego.VPCIP {
+	rc.refreshRoutes(ctx, client)
+
+	rc.Mu.RLock()
+	defer rc.Mu.RUnlock()
+	var ips []linodego.VPCIP
+	for _, instanceIPs := range rc.routes {
+		for _, ip := range instanceIPs {
+			if ip.VPCID == vpcID {
+				ips = append(ips, ip)
+			}
+		}
+	}
+	return ips
+}
+
 type routes struct {
 	client     client.Client
 	instances  *instances
@@ -0,0 +1,154 @@
+package linode
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/appscode/go/wait"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	v1informers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+)
+
+// tlsSecretController updates the NodeBalancer of Services whose HTTPS ports
+// use a TLS Secret that changed, e.g. because cert-manager renewed it. The
+// cloud-provider service controller does not watch Secrets, so the
+// NodeBalancer would otherwise keep serving the old certificate.
+type tlsSecretController struct {
+	loadbalancers   *loadbalancers
+	informer        v1informers.SecretInformer
+	serviceInformer v1informers.ServiceInformer
+	nodeInformer    v1informers.NodeInformer
+
+	queue workqueue.TypedDelayingInterface[any]
+}
+
+func newTLSSecretController(
+	loadbalancers *loadbalancers,
+	informer v1informers.SecretInformer,
+	serviceInformer v1informers.ServiceInformer,
+	nodeInformer v1informers.NodeInformer,
+) *tlsSecretController {
+	return &tlsSecretController{
+		loadbalancers:   loadbalancers,
+		informer:        informer,
+		serviceInformer: serviceInformer,
+		nodeInformer:    nodeInformer,
+		queue:           workqueue.NewTypedDelayingQueueWithConfig[any](workqueue.TypedDelayingQueueConfig[any]{Name: "ccm_tls_secret"}),
+	}
+}
+
+func (s *tlsSecretController) Run(stopCh <-chan struct{}) {
+	if _, err := s.informer.Informer().AddEventHandler(cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, isInInitialList bool) {
+			secret, ok := obj.(*v1.Secret)
+			if !ok || isInInitialList {
+				return
+			}
+			// a Secret created after its Service failed to build
+			s.enqueueServices(secret)
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldSecret, ok := oldObj.(*v1.Secret)
+			if !ok {
+				return
+			}
+			newSecret, ok := newObj.(*v1.Secret)
+			if !ok || reflect.DeepEqual(oldSecret.Data, newSecret.Data) {
+				return
+			}
+			klog.Infof("TLSSecretController will handle updated secret (%s/%s)", newSecret.Namespace, newSecret.Name)
+			s.enqueueServices(newSecret)
+		},
+	}); err != nil {
+		klog.Errorf("TLSSecretController didn't successfully register it's Informer %s", err)
+	}
+
+	go s.informer.Informer().Run(stopCh)
+	if !cache.WaitForCacheSync(stopCh, s.informer.Informer().HasSynced, s.serviceInformer.Informer().HasSynced, s.nodeInformer.Informer().HasSynced) {
+		klog.Errorf("TLSSecretController failed to sync its informers")
+		return
+	}
+
+	wait.Until(s.worker, time.Second, stopCh)
+}
+
+// enqueueServices adds the LoadBalancer Services with a port using secret.
+func (s *tlsSecretController) enqueueServices(secret *v1.Secret) {
+	services, err := s.serviceInformer.Lister().Services(secret.Namespace).List(labels.Everything())
+	if err != nil {
+		klog.Errorf("TLSSecretController failed to list services using secret (%s/%s): %s", secret.Namespace, secret.Name, err)
+		return
+	}
+	for _, service := range services {
+		if service.Spec.Type == v1.ServiceTypeLoadBalancer && usesTLSSecret(service, secret.Name) {
+			s.queue.Add(getServiceNn(service))
+		}
+	}
+}
+
+// usesTLSSecret reports whether a port of service serves the TLS Secret name.
+func usesTLSSecret(service *v1.Service, name string) bool {
+	for _, port := range service.Spec.Ports {
+		annotation, err := getPortConfigAnnotation(service, int(port.Port))
+		if err == nil && annotation.TLSSecretName == name {
+			return true
+		}
+	}
+	return false
+}
+
+// worker runs a worker thread that dequeues Services whose TLS Secret changed
+// and updates their NodeBalancer.
+func (s *tlsSecretController) worker() {
+	for s.processNext() {
+	}
+}
+
+func (s *tlsSecretController) processNext() bool {
+	key, quit := s.queue.Get()
+	if quit {
+		return false
+	}
+	defer s.queue.Done(key)
+
+	name, ok := key.(string)
+	if !ok {
+		klog.Errorf("expected dequeued key to be of type string but got %T", key)
+		return true
+	}
+
+	if err := s.handleSecretChanged(name); err != nil {
+		klog.Errorf("failed to update NodeBalancer for service (%s) TLS secret; retrying in 1 minute: %s", name, err)
+		s.queue.AddAfter(name, retryInterval)
+	}
+	return true
+}
+
+func (s *tlsSecretController) handleSecretChanged(key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+	service, err := s.serviceInformer.Lister().Services(namespace).Get(name)
+	if err != nil {
+		// deleted Services are handled by the service controller
+		return nil
+	}
+	if service.Spec.Type != v1.ServiceTypeLoadBalancer || len(service.Status.LoadBalancer.Ingress) == 0 ||
+		!s.loadbalancers.usesNodeBalancer(service) {
+		return nil
+	}
+
+	nodes, err := getLoadBalancerNodes(s.nodeInformer.Lister())
+	if err != nil {
+		return err
+	}
+
+	klog.Infof("TLSSecretController updating NodeBalancer for service (%s) TLS secret", key)
+	return s.loadbalancers.UpdateLoadBalancer(context.Background(), Options.ClusterName, service, nodes)
+}
@@ -0,0 +1,92 @@
+package linode
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/linode/linode-cloud-controller-manager/cloud/annotations"
+	"github.com/linode/linode-cloud-controller-manager/cloud/linode/client/mocks"
+)
+
+func Test_tlsSecretController_enqueueServices(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mocks.NewMockClient(ctrl)
+
+	factory := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
+	serviceInformer := factory.Core().V1().Services()
+	lb := newLoadbalancers(client, "us-east").(*loadbalancers)
+	controller := newTLSSecretController(lb, factory.Core().V1().Secrets(), serviceInformer, factory.Core().V1().Nodes())
+
+	httpsAnnotations := map[string]string{
+		annotations.AnnLinodePortConfigPrefix + "443": `{"protocol": "https", "tls-secret-name": "tls-secret"}`,
+	}
+	for _, service := range []*v1.Service{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "https", Namespace: "default", Annotations: httpsAnnotations},
+			Spec: v1.ServiceSpec{
+				Type:  v1.ServiceTypeLoadBalancer,
+				Ports: []v1.ServicePort{{Port: 443}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "other-namespace", Namespace: "other", Annotations: httpsAnnotations},
+			Spec: v1.ServiceSpec{
+				Type:  v1.ServiceTypeLoadBalancer,
+				Ports: []v1.ServicePort{{Port: 443}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "http", Namespace: "default"},
+			Spec: v1.ServiceSpec{
+				Type:  v1.ServiceTypeLoadBalancer,
+				Ports: []v1.ServicePort{{Port: 80}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "clusterip", Namespace: "default", Annotations: httpsAnnotations},
+			Spec: v1.ServiceSpec{
+				Type:  v1.ServiceTypeClusterIP,
+				Ports: []v1.ServicePort{{Port: 443}},
+			},
+		},
+	} {
+		assert.NoError(t, serviceInformer.Informer().GetIndexer().Add(service))
+	}
+
+	controller.enqueueServices(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "tls-secret", Namespace: "default"}})
+	assert.Equal(t, 1, controller.queue.Len())
+	key, _ := controller.queue.Get()
+	assert.Equal(t, "default/https", key)
+	controller.queue.Done(key)
+
+	controller.enqueueServices(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "unused", Namespace: "default"}})
+	assert.Equal(t, 0, controller.queue.Len())
+}
+
+func Test_tlsSecretController_handleSecretChanged(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	// no NodeBalancer is updated for these services, so the client must not be called
+	client := mocks.NewMockClient(ctrl)
+
+	factory := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
+	serviceInformer := factory.Core().V1().Services()
+	lb := newLoadbalancers(client, "us-east").(*loadbalancers)
+	controller := newTLSSecretController(lb, factory.Core().V1().Secrets(), serviceInformer, factory.Core().V1().Nodes())
+
+	assert.NoError(t, serviceInformer.Informer().GetIndexer().Add(&v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "pending", Namespace: "default"},
+		Spec:       v1.ServiceSpec{Type: v1.ServiceTypeLoadBalancer},
+	}))
+
+	for _, key := range []string{"default/pending", "default/deleted"} {
+		assert.NoError(t, controller.handleSecretChanged(key), key)
+	}
+}
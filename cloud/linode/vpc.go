@@ -18,10 +18,16 @@ var (
 	Mu sync.RWMutex
 	// vpcIDs map stores vpc id's for given vpc labels
 	vpcIDs = make(map[string]int, 0)
-	// subnetIDs map stores subnet id's for given subnet labels
-	subnetIDs = make(map[string]int, 0)
+	// subnetIDs map stores subnet id's for given subnet labels, which are
+	// only unique within a VPC
+	subnetIDs = make(map[subnetKey]int, 0)
 )
 
+type subnetKey struct {
+	vpcID int
+	label string
+}
+
 type vpcLookupError struct {
 	value string
 }
@@ -81,7 +87,8 @@ func GetSubnetID(ctx context.Context, client client.Client, vpcID int, subnetNam
 	defer Mu.Unlock()
 
 	// Check if map contains the id for the given label
-	if subnetid, ok := subnetIDs[subnetName]; ok {
+	key := subnetKey{vpcID: vpcID, label: subnetName}
+	if subnetid, ok := subnetIDs[key]; ok {
 		return subnetid, nil
 	}
 	// Otherwise, get it from linodego.ListVPCSubnets()
@@ -91,7 +98,7 @@ func GetSubnetID(ctx context.Context, client client.Client, vpcID int, subnetNam
 	}
 	for _, subnet := range subnets {
 		if subnet.Label == subnetName {
-			subnetIDs[subnetName] = subnet.ID
+			subnetIDs[key] = subnet.ID
 			return subnet.ID, nil
 		}
 	}
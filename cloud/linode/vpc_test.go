@@ -7,11 +7,16 @@ import (
 	"reflect"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/linode/linode-cloud-controller-manager/cloud/annotations"
 	"github.com/linode/linode-cloud-controller-manager/cloud/linode/client/mocks"
 	"github.com/linode/linodego"
 	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
 )
 
 func TestGetAllVPCIDs(t *testing.T) {
@@ -155,13 +160,13 @@ func TestGetVPCIPAddresses(t *testing.T) {
 		defer func() { Options.SubnetNames = sn }()
 		Options.SubnetNames = "subnet4"
 		vpcIDs = map[string]int{"test1": 1}
-		subnetIDs = map[string]int{"subnet1": 1}
+		subnetIDs = map[subnetKey]int{{vpcID: 1, label: "subnet1"}: 1}
 		client.EXPECT().ListVPCs(gomock.Any(), gomock.Any()).Times(1).Return([]linodego.VPC{{ID: 10, Label: "test10"}}, nil)
 		client.EXPECT().ListVPCSubnets(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return([]linodego.VPCSubnet{{ID: 4, Label: "subnet4"}}, nil)
 		client.EXPECT().ListVPCIPAddresses(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return([]linodego.VPCIP{}, nil)
 		_, err := GetVPCIPAddresses(context.TODO(), client, "test10")
 		assert.NoError(t, err)
-		_, exists := subnetIDs["subnet4"]
+		_, exists := subnetIDs[subnetKey{vpcID: 10, label: "subnet4"}]
 		assert.True(t, exists, "subnet4 should be present in subnetIDs map")
 	})
 }
@@ -171,14 +176,14 @@ func TestGetSubnetID(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		defer ctrl.Finish()
 		client := mocks.NewMockClient(ctrl)
-		subnetIDs = map[string]int{"test1": 1, "test2": 2, "test3": 3}
+		subnetIDs = map[subnetKey]int{{label: "test1"}: 1, {label: "test2"}: 2, {label: "test3"}: 3}
 		got, err := GetSubnetID(context.TODO(), client, 0, "test3")
 		if err != nil {
 			t.Errorf("GetSubnetID() error = %v", err)
 			return
 		}
-		if got != subnetIDs["test3"] {
-			t.Errorf("GetSubnetID() = %v, want %v", got, subnetIDs["test3"])
+		if got != 3 {
+			t.Errorf("GetSubnetID() = %v, want %v", got, 3)
 		}
 	})
 
@@ -186,14 +191,14 @@ func TestGetSubnetID(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		defer ctrl.Finish()
 		client := mocks.NewMockClient(ctrl)
-		subnetIDs = map[string]int{"test1": 1, "test2": 2, "test3": 3}
+		subnetIDs = map[subnetKey]int{{label: "test1"}: 1, {label: "test2"}: 2, {label: "test3"}: 3}
 		client.EXPECT().ListVPCSubnets(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return([]linodego.VPCSubnet{}, errors.New("error"))
 		got, err := GetSubnetID(context.TODO(), client, 0, "test4")
 		assert.Error(t, err)
 		if got != 0 {
 			t.Errorf("GetSubnetID() = %v, want %v", got, 0)
 		}
-		_, exists := subnetIDs["test4"]
+		_, exists := subnetIDs[subnetKey{label: "test4"}]
 		assert.False(t, exists, "subnet4 should not be present in subnetIDs")
 	})
 
@@ -201,7 +206,7 @@ func TestGetSubnetID(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		defer ctrl.Finish()
 		client := mocks.NewMockClient(ctrl)
-		subnetIDs = map[string]int{"test1": 1, "test2": 2, "test3": 3}
+		subnetIDs = map[subnetKey]int{{label: "test1"}: 1, {label: "test2"}: 2, {label: "test3"}: 3}
 		client.EXPECT().ListVPCSubnets(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return([]linodego.VPCSubnet{}, nil)
 		got, err := GetSubnetID(context.TODO(), client, 0, "test4")
 		assert.ErrorIs(t, err, subnetLookupError{"test4"})
@@ -214,7 +219,7 @@ func TestGetSubnetID(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		defer ctrl.Finish()
 		client := mocks.NewMockClient(ctrl)
-		subnetIDs = map[string]int{"test1": 1, "test2": 2, "test3": 3}
+		subnetIDs = map[subnetKey]int{{label: "test1"}: 1, {label: "test2"}: 2, {label: "test3"}: 3}
 		client.EXPECT().ListVPCSubnets(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return([]linodego.VPCSubnet{{ID: 4, Label: "test4"}}, nil)
 		got, err := GetSubnetID(context.TODO(), client, 0, "test4")
 		assert.NoError(t, err)
@@ -222,4 +227,179 @@ func TestGetSubnetID(t *testing.T) {
 			t.Errorf("GetSubnetID() = %v, want %v", got, 4)
 		}
 	})
+
+	t.Run("subnet with the same label in another VPC", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		client := mocks.NewMockClient(ctrl)
+		subnetIDs = map[subnetKey]int{{vpcID: 1, label: "default"}: 1}
+		client.EXPECT().ListVPCSubnets(gomock.Any(), 2, gomock.Any()).Times(1).Return([]linodego.VPCSubnet{{ID: 5, Label: "default"}}, nil)
+		got, err := GetSubnetID(context.TODO(), client, 2, "default")
+		assert.NoError(t, err)
+		if got != 5 {
+			t.Errorf("GetSubnetID() = %v, want %v", got, 5)
+		}
+	})
+}
+
+func Test_getNodeBalancerVPC(t *testing.T) {
+	vpcIDs = map[string]int{"vpc": 1, "other": 2}
+	subnetIDs = map[subnetKey]int{{vpcID: 1, label: "subnet"}: 20}
+	vpcNames, subnetNames := Options.VPCNames, Options.SubnetNames
+	defer func() {
+		Options.VPCNames, Options.SubnetNames = vpcNames, subnetNames
+	}()
+	Options.VPCNames = "vpc,other"
+
+	nodes := []*v1.Node{
+		{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}, Spec: v1.NodeSpec{ProviderID: providerIDPrefix + "101"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "node-2"}, Spec: v1.NodeSpec{ProviderID: providerIDPrefix + "102"}},
+	}
+	// the VPC IPs cached for the route controller, by Linode ID
+	cachedRoutes := map[int][]linodego.VPCIP{
+		101: {
+			{Address: ptr.To("10.0.0.1"), LinodeID: 101, VPCID: 1, SubnetID: 10},
+			{AddressRange: ptr.To("10.0.1.64/28"), LinodeID: 101, VPCID: 1, SubnetID: 20},
+			{Address: ptr.To("10.1.0.1"), LinodeID: 101, VPCID: 2, SubnetID: 30},
+		},
+		102: {{Address: ptr.To("10.0.0.2"), LinodeID: 102, VPCID: 1, SubnetID: 10}},
+		103: {{Address: ptr.To("10.0.1.3"), LinodeID: 103, VPCID: 1, SubnetID: 20}},
+	}
+	nb := &linodego.NodeBalancer{ID: 5}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		subnetNames string
+		nodes       []*v1.Node
+		nb          *linodego.NodeBalancer
+		routes      map[int][]linodego.VPCIP
+		expect      func(*mocks.MockClient)
+		want        *nodeBalancerVPC
+		wantErr     bool
+	}{
+		{
+			name:  "private IP backends",
+			nodes: nodes,
+		},
+		{
+			name:        "subnet of the backends",
+			annotations: map[string]string{annotations.AnnLinodeNodeBalancerBackendVPCName: "vpc"},
+			nodes:       nodes,
+			routes:      cachedRoutes,
+			want:        &nodeBalancerVPC{subnetID: 10, addresses: map[int]string{101: "10.0.0.1", 102: "10.0.0.2"}},
+		},
+		{
+			name: "subnet annotation",
+			annotations: map[string]string{
+				annotations.AnnLinodeNodeBalancerBackendVPCName:    "vpc",
+				annotations.AnnLinodeNodeBalancerBackendSubnetName: "subnet",
+			},
+			nodes:  nodes,
+			routes: cachedRoutes,
+			want:   &nodeBalancerVPC{subnetID: 20, addresses: map[int]string{103: "10.0.1.3"}},
+		},
+		{
+			name: "subnet not in subnet names",
+			annotations: map[string]string{
+				annotations.AnnLinodeNodeBalancerBackendVPCName:    "vpc",
+				annotations.AnnLinodeNodeBalancerBackendSubnetName: "subnet",
+			},
+			subnetNames: "other-subnet",
+			nodes:       nodes,
+			routes:      cachedRoutes,
+			wantErr:     true,
+		},
+		{
+			name:        "vpc not in vpc names",
+			annotations: map[string]string{annotations.AnnLinodeNodeBalancerBackendVPCName: "unknown"},
+			nodes:       nodes,
+			routes:      cachedRoutes,
+			wantErr:     true,
+		},
+		{
+			name:        "backends in several subnets",
+			annotations: map[string]string{annotations.AnnLinodeNodeBalancerBackendVPCName: "vpc"},
+			nodes: append(nodes, &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "node-3"},
+				Spec:       v1.NodeSpec{ProviderID: providerIDPrefix + "103"},
+			}),
+			routes:  cachedRoutes,
+			wantErr: true,
+		},
+		{
+			name:        "no cached IPs",
+			annotations: map[string]string{annotations.AnnLinodeNodeBalancerBackendVPCName: "vpc"},
+			nodes:       nodes,
+			expect: func(c *mocks.MockClient) {
+				c.EXPECT().ListVPCIPAddresses(gomock.Any(), gomock.Any(), gomock.Any()).Times(2).Return(nil, errors.New("error"))
+			},
+			wantErr: true,
+		},
+		{
+			name:        "existing NodeBalancer without VPC",
+			annotations: map[string]string{annotations.AnnLinodeNodeBalancerBackendVPCName: "vpc"},
+			nodes:       nodes,
+			nb:          nb,
+			expect: func(c *mocks.MockClient) {
+				c.EXPECT().ListNodeBalancerVPCConfigs(gomock.Any(), 5, gomock.Any()).Return(nil, nil)
+			},
+		},
+		{
+			name:        "existing NodeBalancer in VPC",
+			annotations: map[string]string{annotations.AnnLinodeNodeBalancerBackendVPCName: "vpc"},
+			nodes:       nodes,
+			nb:          nb,
+			routes:      cachedRoutes,
+			expect: func(c *mocks.MockClient) {
+				c.EXPECT().ListNodeBalancerVPCConfigs(gomock.Any(), 5, gomock.Any()).Return([]linodego.NodeBalancerVPCConfig{{VPCID: 1, SubnetID: 20}}, nil)
+			},
+			want: &nodeBalancerVPC{subnetID: 20, addresses: map[int]string{103: "10.0.1.3"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			client := mocks.NewMockClient(ctrl)
+			if tt.expect != nil {
+				tt.expect(client)
+			}
+			Options.SubnetNames = tt.subnetNames
+
+			// a fresh cache is used without listing the VPC IPs again
+			cache := &routeCache{routes: tt.routes, ttl: time.Minute}
+			if tt.routes != nil {
+				cache.lastUpdate = time.Now()
+			}
+			lb := &loadbalancers{client: client, routeCache: cache}
+			service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "test", Annotations: tt.annotations}}
+			got, err := lb.getNodeBalancerVPC(context.TODO(), service, tt.nodes, tt.nb)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_nodeBalancerVPC_filterNodes(t *testing.T) {
+	nodes := []*v1.Node{
+		{ObjectMeta: metav1.ObjectMeta{Name: "in-vpc"}, Spec: v1.NodeSpec{ProviderID: providerIDPrefix + "101"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "outside-vpc"}, Spec: v1.NodeSpec{ProviderID: providerIDPrefix + "102"}},
+	}
+
+	var noVPC *nodeBalancerVPC
+	assert.Equal(t, nodes, noVPC.filterNodes(nodes))
+
+	vpc := &nodeBalancerVPC{subnetID: 10, addresses: map[int]string{101: "10.0.0.1"}}
+	filtered := vpc.filterNodes(nodes)
+	assert.Equal(t, nodes[:1], filtered)
+
+	lb := &loadbalancers{}
+	opts := lb.buildNodeBalancerNodeConfigRebuildOptions(filtered[0], 30000, vpc)
+	assert.Equal(t, "10.0.0.1:30000", opts.Address)
+	assert.Equal(t, 10, opts.SubnetID)
 }
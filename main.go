@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"time"
 
 	"k8s.io/component-base/logs"
 
@@ -85,10 +86,28 @@ func main() {
 	command.Flags().StringVar(&linode.Options.VPCName, "vpc-name", "", "[deprecated: use vpc-names instead] vpc name whose routes will be managed by route-controller")
 	command.Flags().StringVar(&linode.Options.VPCNames, "vpc-names", "", "comma separated vpc names whose routes will be managed by route-controller")
 	command.Flags().StringVar(&linode.Options.SubnetNames, "subnet-names", "", "comma separated subnet names whose routes will be managed by route-controller (requires vpc-names flag to also be set)")
+	command.Flags().BoolVar(&linode.Options.EnableNodeBalancerVPCBackends, "enable-nodebalancer-vpc-backends", false, "creates NodeBalancers attached to a subnet of vpc-names, backed by the VPC IPs of the nodes")
 	command.Flags().StringVar(&linode.Options.LoadBalancerType, "load-balancer-type", "nodebalancer", "configures which type of load-balancing to use for LoadBalancer Services (options: nodebalancer, cilium-bgp)")
+	command.Flags().IntVar(&linode.Options.DefaultCheckInterval, "default-check-interval", linode.Options.DefaultCheckInterval, "seconds between the NodeBalancer health checks of Services without the check-interval annotation (2-3600)")
+	command.Flags().IntVar(&linode.Options.DefaultCheckTimeout, "default-check-timeout", linode.Options.DefaultCheckTimeout, "seconds to wait for a NodeBalancer health check of Services without the check-timeout annotation (1-30)")
+	command.Flags().IntVar(&linode.Options.DefaultCheckAttempts, "default-check-attempts", linode.Options.DefaultCheckAttempts, "failed NodeBalancer health checks removing a backend of Services without the check-attempts annotation (1-30)")
+	command.Flags().BoolVar(&linode.Options.DefaultCheckPassive, "default-check-passive", linode.Options.DefaultCheckPassive, "enables passive NodeBalancer health checks of Services without the check-passive annotation")
+	command.Flags().StringVar(&linode.Options.DefaultProtocol, "default-protocol", linode.Options.DefaultProtocol, "NodeBalancer protocol of the TCP ports of Services without the default-protocol annotation (options: tcp, http, https)")
+	command.Flags().StringVar(&linode.Options.DefaultProxyProtocol, "default-proxy-protocol", linode.Options.DefaultProxyProtocol, "NodeBalancer proxy protocol of the TCP ports of Services without the default-proxy-protocol annotation (options: none, v1, v2)")
+	command.Flags().IntVar(&linode.Options.DefaultThrottle, "default-throttle", linode.Options.DefaultThrottle, "NodeBalancer client connection throttle of Services without the throttle annotation (0-20, 0 to disable)")
+	command.Flags().StringSliceVar(&linode.Options.DefaultNodeBalancerTags, "default-nodebalancer-tags", []string{}, "Linode tags to apply to the NodeBalancers of Services without the tags annotation")
+	command.Flags().StringToStringVar(&linode.Options.LoadBalancerClasses, "load-balancer-classes", map[string]string{}, "load balancer classes handled by the CCM and the type of load-balancing of their Services (e.g. linode.com/nodebalancer=nodebalancer,linode.com/cilium-bgp=cilium-bgp)")
 	command.Flags().StringVar(&linode.Options.BGPNodeSelector, "bgp-node-selector", "", "node selector to use to perform shared IP fail-over with BGP (e.g. cilium-bgp-peering=true")
 	command.Flags().StringVar(&linode.Options.IpHolderSuffix, "ip-holder-suffix", "", "suffix to append to the ip holder name when using shared IP fail-over with BGP (e.g. ip-holder-suffix=my-cluster-name")
 	command.Flags().StringSliceVar(&linode.Options.NodeBalancerTags, "nodebalancer-tags", []string{}, "Linode tags to apply to all NodeBalancers")
+	command.Flags().BoolVar(&linode.Options.EnableNodeBalancerGC, "enable-nodebalancer-gc", false, "enables periodic deletion of NodeBalancers and firewalls whose Service no longer exists")
+	command.Flags().DurationVar(&linode.Options.NodeBalancerGCInterval, "nodebalancer-gc-interval", time.Hour, "how often to look for leaked NodeBalancers and firewalls")
+	command.Flags().DurationVar(&linode.Options.NodeBalancerGCGracePeriod, "nodebalancer-gc-grace-period", 24*time.Hour, "minimum age of a leaked NodeBalancer or firewall before it is deleted")
+	command.Flags().BoolVar(&linode.Options.NodeBalancerGCDryRun, "nodebalancer-gc-dry-run", false, "only log leaked NodeBalancers and firewalls instead of deleting them")
+	command.Flags().StringVar(&linode.Options.AdmissionWebhookAddress, "admission-webhook-bind-address", "", "address to serve the Service annotation validation webhook on (e.g. :10260), disabled if empty")
+	command.Flags().StringVar(&linode.Options.AdmissionWebhookCertDir, "admission-webhook-cert-dir", "/etc/ccm-linode/webhook", "directory holding the tls.crt and tls.key served by the admission webhook")
+	command.Flags().StringToStringVar(&linode.Options.IPFeeds, "firewall-ip-feeds", map[string]string{}, "IP feeds firewall ACLs can reference by name, and the URL serving one address or CIDR per line (e.g. office=https://example.com/office-ips.txt)")
+	command.Flags().DurationVar(&linode.Options.IPFeedRefreshInterval, "firewall-ip-feed-refresh-interval", time.Hour, "how often to refresh the IP feeds referenced by firewall ACLs")
 
 	// Set static flags
 	command.Flags().VisitAll(func(fl *pflag.Flag) {
@@ -149,6 +168,8 @@ func main() {
 }
 
 func cloudInitializer(config *config.CompletedConfig) cloudprovider.Interface {
+	linode.Options.ClusterName = config.ComponentConfig.KubeCloudShared.ClusterName
+
 	// initialize cloud provider with the cloud provider name and config file provided
 	cloud, err := cloudprovider.InitCloudProvider(linode.ProviderName, "")
 	if err != nil {